@@ -2,62 +2,188 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"runtime"
+	"log/slog"
+	"sync"
+	"time"
 
-	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+	"github.com/kmc-jp/GoogleHomeNotifier/cache"
 )
 
+type TtsInputAttr struct {
+	Text string
+	// Voice is a speaker name or ID. The default speaker is used if it is empty.
+	Voice   string
+	Prosody Prosody
+}
+
+// TtsOutputAttr is a synthesized sound kept in memory.
 type TtsOutputAttr struct {
-	FilePath string
+	Data     []byte
+	MIMEType string
 	Error    error
 }
 
-func StartTTS(settings VoicevoxSetting) (chan string, chan TtsOutputAttr, error) {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
+// TTSPool synthesizes texts with several workers.
+// The texts of the message being played are synthesized before the ones prefetched for the next messages.
+type TTSPool struct {
+	engine     TTSEngine
+	audioCache *cache.Cache
+	workers    int
 
-	err := voicevox.Initialize(voicevox.VoicevoxInitializeOptions{
-		AccelerationMode: voicevox.VOICEVOX_ACCELERATION_MODE_AUTO,
-		CpuNumThreads:    0,
-		LoadAllModels:    false,
-		OpenJtalkDictDir: settings.OpenJtalkDictDir,
-	})
-	if err != nil {
-		return nil, nil, fmt.Errorf("Initialize: %v", err)
+	foreground chan ttsJob
+	background chan ttsJob
+
+	mu sync.Mutex
+	// inflight are the sounds being synthesized by their cache keys
+	inflight map[string]*ttsCall
+}
+
+type ttsJob struct {
+	input  TtsInputAttr
+	output chan TtsOutputAttr
+	// prefetch is set for the jobs of Prefetch, which are not counted as cache lookups
+	prefetch bool
+}
+
+// ttsCall is a synthesis which other jobs of the same text wait for.
+type ttsCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// StartTTS starts workers which synthesize texts with engine.
+// If the engine is a CacheKeyer, the sounds are looked up in audioCache first and stored there.
+// audioCache may be nil.
+func StartTTS(engine TTSEngine, audioCache *cache.Cache, workers int) *TTSPool {
+	if workers < 1 {
+		workers = 1
 	}
 
-	err = voicevox.LoadModel(settings.SpeakerID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("LoadModel: %v", err)
+	var p = &TTSPool{
+		engine:     engine,
+		audioCache: audioCache,
+		workers:    workers,
+		foreground: make(chan ttsJob, workers),
+		background: make(chan ttsJob, maxPrefetchJobs),
+		inflight:   map[string]*ttsCall{},
 	}
 
-	var inputchan = make(chan string)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
 
-	var outputchan = make(chan TtsOutputAttr)
+	return p
+}
 
-	go func() {
-		for text := range inputchan {
-			output, err := voicevox.TTS(text, 3, voicevox.VoicevoxTtsOptions{Kana: false})
-			if err != nil {
-				outputchan <- TtsOutputAttr{Error: fmt.Errorf("TTS: %v", err)}
-				continue
-			}
+// maxPrefetchJobs is the number of prefetched texts which can wait for a worker.
+// More texts are not prefetched.
+const maxPrefetchJobs = 64
 
-			f, err := os.CreateTemp("", "GoogleHomeSound*.wav")
-			if err != nil {
-				outputchan <- TtsOutputAttr{Error: fmt.Errorf("Create: %v", err)}
-				continue
+func (p *TTSPool) work() {
+	for {
+		var job ttsJob
+		// a foreground job is taken first if both are waiting
+		select {
+		case job = <-p.foreground:
+		default:
+			select {
+			case job = <-p.foreground:
+			case job = <-p.background:
 			}
+		}
+
+		output, err := p.synthesize(job.input.Text, TTSOptions{Voice: job.input.Voice, Prosody: job.input.Prosody}, job.prefetch)
+		if err != nil {
+			job.output <- TtsOutputAttr{Error: err}
+			continue
+		}
+		job.output <- TtsOutputAttr{Data: output, MIMEType: "audio/wav"}
+	}
+}
+
+// Workers returns the number of texts synthesized at once.
+func (p *TTSPool) Workers() int {
+	return p.workers
+}
 
-			f.Write(output)
-			f.Close()
+// Synthesize starts synthesizing input, and returns the channel the sound is sent to.
+// The texts are taken by the workers in the order they are given.
+// It blocks while Workers texts are already waiting for a worker.
+func (p *TTSPool) Synthesize(input TtsInputAttr) <-chan TtsOutputAttr {
+	var output = make(chan TtsOutputAttr, 1)
+	p.foreground <- ttsJob{input: input, output: output}
+	return output
+}
+
+// Prefetch synthesizes input into the cache when a worker is free.
+// It does nothing if the sounds of the engine are not cached, or too many texts are waiting.
+func (p *TTSPool) Prefetch(input TtsInputAttr) {
+	if _, ok := p.engine.(CacheKeyer); p.audioCache == nil || !ok {
+		return
+	}
 
-			voicevox.WavFree(output)
+	select {
+	case p.background <- ttsJob{input: input, output: make(chan TtsOutputAttr, 1), prefetch: true}:
+	default:
+	}
+}
 
-			outputchan <- TtsOutputAttr{FilePath: f.Name()}
+// synthesize returns the cached sound of text, or synthesizes and caches it.
+// A text which is being synthesized by another worker is waited for.
+// With prefetch, nothing is returned for a text which is already cached.
+func (p *TTSPool) synthesize(text string, options TTSOptions, prefetch bool) ([]byte, error) {
+	keyer, ok := p.engine.(CacheKeyer)
+	if p.audioCache == nil || !ok {
+		return p.synthesizeUncached(text, options)
+	}
+
+	parts, err := keyer.CacheKey(text, options)
+	if err != nil {
+		return nil, fmt.Errorf("CacheKey: %v", err)
+	}
+	var key = cache.Key(parts...)
+
+	if prefetch {
+		if p.audioCache.Has(key) {
+			return nil, nil
 		}
-	}()
+	} else if data, ok := p.audioCache.Get(key); ok {
+		return data, nil
+	}
 
-	return inputchan, outputchan, nil
+	p.mu.Lock()
+	if call, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+	var call = &ttsCall{done: make(chan struct{})}
+	p.inflight[key] = call
+	p.mu.Unlock()
+
+	call.data, call.err = p.synthesizeUncached(text, options)
+	if call.err == nil {
+		err = p.audioCache.Put(key, call.data)
+		if err != nil {
+			slog.Warn("Failed to cache the sound", "error", err)
+		}
+	}
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	p.mu.Unlock()
+	close(call.done)
+
+	return call.data, call.err
+}
+
+func (p *TTSPool) synthesizeUncached(text string, options TTSOptions) ([]byte, error) {
+	var start = time.Now()
+	data, err := p.engine.Synthesize(text, options)
+	if err != nil {
+		return nil, fmt.Errorf("Synthesize: %v", err)
+	}
+	synthesisSeconds.Observe(time.Since(start).Seconds())
+	return data, nil
 }
@@ -0,0 +1,72 @@
+package audio
+
+// Convert returns p converted to sampleRate and channels.
+// The channels are mixed down to mono or copied from mono,
+// and the sample rate is converted by linear interpolation.
+func (p *PCM) Convert(sampleRate, channels int) *PCM {
+	var converted = p.convertChannels(channels)
+	return converted.resample(sampleRate)
+}
+
+func (p *PCM) convertChannels(channels int) *PCM {
+	if p.Channels == channels {
+		return p
+	}
+
+	var frames = p.Frames()
+	var out = &PCM{SampleRate: p.SampleRate, Channels: channels, Samples: make([]int16, frames*channels)}
+
+	for f := 0; f < frames; f++ {
+		var frame = p.Samples[f*p.Channels : (f+1)*p.Channels]
+
+		if channels == 1 {
+			var sum = 0
+			for _, v := range frame {
+				sum += int(v)
+			}
+			out.Samples[f] = int16(sum / p.Channels)
+			continue
+		}
+
+		for c := 0; c < channels; c++ {
+			// mono is copied to every channel, and missing channels are copied from the last one
+			var src = c
+			if src >= p.Channels {
+				src = p.Channels - 1
+			}
+			out.Samples[f*channels+c] = frame[src]
+		}
+	}
+
+	return out
+}
+
+func (p *PCM) resample(sampleRate int) *PCM {
+	if p.SampleRate == sampleRate {
+		return p
+	}
+
+	var frames = p.Frames()
+	var outFrames = int(int64(frames) * int64(sampleRate) / int64(p.SampleRate))
+	var out = &PCM{SampleRate: sampleRate, Channels: p.Channels, Samples: make([]int16, outFrames*p.Channels)}
+
+	var step = float64(p.SampleRate) / float64(sampleRate)
+	for f := 0; f < outFrames; f++ {
+		var pos = float64(f) * step
+		var i = int(pos)
+		var frac = pos - float64(i)
+
+		var next = i + 1
+		if next >= frames {
+			next = frames - 1
+		}
+
+		for c := 0; c < p.Channels; c++ {
+			var a = float64(p.Samples[i*p.Channels+c])
+			var b = float64(p.Samples[next*p.Channels+c])
+			out.Samples[f*p.Channels+c] = clamp(a + (b-a)*frac)
+		}
+	}
+
+	return out
+}
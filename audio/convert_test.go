@@ -0,0 +1,42 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var tests = []struct {
+		name       string
+		in         *PCM
+		sampleRate int
+		channels   int
+		want       []int16
+	}{
+		{"same format", &PCM{24000, 1, []int16{1, 2, 3}}, 24000, 1, []int16{1, 2, 3}},
+		{"mono to stereo", &PCM{24000, 1, []int16{1, -2}}, 24000, 2, []int16{1, 1, -2, -2}},
+		{"stereo to mono", &PCM{24000, 2, []int16{100, 200, -100, 101}}, 24000, 1, []int16{150, 0}},
+		{"stereo to mono at the limit", &PCM{24000, 2, []int16{32767, 32767, -32768, -32768}}, 24000, 1, []int16{32767, -32768}},
+		{"stereo to 3 channels", &PCM{24000, 2, []int16{1, 2}}, 24000, 3, []int16{1, 2, 2}},
+		{"upsample", &PCM{1000, 1, []int16{0, 100, 200}}, 2000, 1, []int16{0, 50, 100, 150, 200, 200}},
+		{"downsample", &PCM{2000, 1, []int16{0, 50, 100, 150, 200, 250}}, 1000, 1, []int16{0, 100, 200}},
+		{"non-integer ratio", &PCM{3000, 1, []int16{0, 30, 60}}, 2000, 1, []int16{0, 45}},
+		{"resample stereo", &PCM{1000, 2, []int16{0, 100, 100, 0}}, 2000, 2, []int16{0, 100, 50, 50, 100, 0, 100, 0}},
+		{"mix and resample", &PCM{1000, 2, []int16{0, 100, 100, 200}}, 2000, 1, []int16{50, 100, 150, 150}},
+		{"empty", &PCM{24000, 1, nil}, 48000, 2, []int16{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = tt.in.Convert(tt.sampleRate, tt.channels)
+			if got.SampleRate != tt.sampleRate || got.Channels != tt.channels {
+				t.Errorf("Convert = %d Hz %d ch, want %d Hz %d ch", got.SampleRate, got.Channels, tt.sampleRate, tt.channels)
+			}
+			if len(got.Samples) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got.Samples, tt.want) {
+					t.Errorf("Convert = %v, want %v", got.Samples, tt.want)
+				}
+			}
+		})
+	}
+}
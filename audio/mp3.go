@@ -0,0 +1,222 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+
+	"github.com/braheezy/shine-mp3/pkg/mp3"
+)
+
+// mp3SampleRates are the sample rates of MPEG audio in ascending order.
+var mp3SampleRates = []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// mp3Bitrates are the bitrates of Layer III in kbps by the MPEG version
+// in the encoder: 0 is MPEG 2.5, 2 is MPEG 2 and 3 is MPEG 1.
+var mp3Bitrates = map[int][]int{
+	0: {8, 16, 24, 32, 40, 48, 56, 64},
+	2: {8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	3: {32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+}
+
+// mp3SampleRate returns the sample rate sounds of sampleRate are encoded at,
+// which is the lowest MPEG sample rate not below it.
+func mp3SampleRate(sampleRate int) int {
+	for _, r := range mp3SampleRates {
+		if r >= sampleRate {
+			return r
+		}
+	}
+	return mp3SampleRates[len(mp3SampleRates)-1]
+}
+
+// mp3Version returns the MPEG version of an MPEG sample rate as the encoder numbers it.
+func mp3Version(rate int) int {
+	switch {
+	case rate <= 12000:
+		return 0
+	case rate <= 24000:
+		return 2
+	}
+	return 3
+}
+
+// MP3Bitrates returns the bitrates in kbps which sounds of sampleRate can be encoded at:
+//
+//	8-12 kHz   8-64 in steps of 8
+//	16-24 kHz  8-64 in steps of 8 and 80-160 in steps of 16
+//	32-48 kHz  32-64 in steps of 8, 80-160 in steps of 16 except 144, 192-256 in steps of 32 and 320
+func MP3Bitrates(sampleRate int) []int {
+	return mp3Bitrates[mp3Version(mp3SampleRate(sampleRate))]
+}
+
+// CheckMP3Bitrate returns an error if sounds of sampleRate cannot be encoded at bitrate kbps.
+func CheckMP3Bitrate(bitrate, sampleRate int) error {
+	var bitrates = MP3Bitrates(sampleRate)
+	if !slices.Contains(bitrates, bitrate) {
+		return fmt.Errorf("bitrate %d kbps is not available for sounds of %d Hz: choose from %v", bitrate, sampleRate, bitrates)
+	}
+	return nil
+}
+
+// MP3Encoder encodes sounds into a constant bitrate MP3 stream.
+// The sounds written one after another are encoded without gaps.
+type MP3Encoder struct {
+	w          io.Writer
+	enc        *mp3.Encoder
+	sampleRate int
+	channels   int
+	frameSize  int
+	// pending are the samples which do not fill a frame yet
+	pending []int16
+	// frame is given to the encoder. It has a spare sample for each channel,
+	// since the encoder moves its pointer past the last sample of each channel.
+	frame  []int16
+	closed bool
+}
+
+// NewMP3Encoder returns an encoder of bitrate kbps for sounds of sampleRate and channels.
+// The sounds are resampled if MPEG audio does not have sampleRate,
+// and mixed down to stereo if they have more channels.
+func NewMP3Encoder(w io.Writer, sampleRate, channels, bitrate int) (*MP3Encoder, error) {
+	var rate = mp3SampleRate(sampleRate)
+
+	if channels > 2 {
+		channels = 2
+	}
+
+	var enc = mp3.NewEncoder(rate, channels)
+	err := setMP3Bitrate(enc, bitrate)
+	if err != nil {
+		return nil, err
+	}
+
+	var frameSize = int(enc.Mpeg.GranulesPerFrame) * mp3.GRANULE_SIZE * channels
+
+	return &MP3Encoder{
+		w:          w,
+		enc:        enc,
+		sampleRate: rate,
+		channels:   channels,
+		frameSize:  frameSize,
+		frame:      make([]int16, frameSize, frameSize+channels),
+	}, nil
+}
+
+// Write encodes p. The samples which do not fill a frame are kept until the next Write or Close.
+func (e *MP3Encoder) Write(p *PCM) error {
+	e.pending = append(e.pending, p.Convert(e.sampleRate, e.channels).Samples...)
+
+	var i = 0
+	for ; i+e.frameSize <= len(e.pending); i += e.frameSize {
+		// the encoder takes a frame at a time
+		err := e.writeFrame(e.pending[i : i+e.frameSize])
+		if err != nil {
+			return fmt.Errorf("Write: %v", err)
+		}
+	}
+	e.pending = append(e.pending[:0], e.pending[i:]...)
+
+	return nil
+}
+
+// Close encodes the rest of the samples padded with silence. It does not close the writer.
+func (e *MP3Encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	if len(e.pending) > 0 {
+		var last = append(e.pending, make([]int16, e.frameSize-len(e.pending))...)
+		e.pending = nil
+
+		err := e.writeFrame(last)
+		if err != nil {
+			return fmt.Errorf("Write: %v", err)
+		}
+	}
+
+	return flushMP3(e.w, e.enc)
+}
+
+func (e *MP3Encoder) writeFrame(samples []int16) error {
+	copy(e.frame, samples)
+	return e.enc.Write(e.w, e.frame)
+}
+
+// flushMP3 writes the end of the last frame.
+// The encoder writes its bitstream 4 bytes at a time and keeps the rest of the bits
+// for the next frame, but has no method to flush them, so they are read from its fields.
+func flushMP3(w io.Writer, enc *mp3.Encoder) error {
+	var bs = reflect.ValueOf(enc).Elem().FieldByName("bitstream")
+	var cache, cacheBits = bs.FieldByName("cache"), bs.FieldByName("cacheBits")
+	if !cache.IsValid() || !cacheBits.IsValid() {
+		return errors.New("Flush: the bitstream of the encoder is not known")
+	}
+
+	var bits = 32 - int(cacheBits.Int())
+	var rest = binary.BigEndian.AppendUint32(nil, uint32(cache.Uint()))[:(bits+7)/8]
+
+	_, err := w.Write(rest)
+	if err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// EncodeMP3 encodes p as a constant bitrate MP3 of bitrate kbps.
+func EncodeMP3(p *PCM, bitrate int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc, err := NewMP3Encoder(&buf, p.SampleRate, p.Channels, bitrate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Write(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// setMP3Bitrate changes the bitrate of enc, which mp3.NewEncoder fixes to 128 kbps.
+// The frame size is calculated as mp3.NewEncoder does.
+func setMP3Bitrate(enc *mp3.Encoder, bitrate int) error {
+	var version = int(enc.Mpeg.Version)
+
+	var index = -1
+	for i, b := range mp3Bitrates[version] {
+		if b == bitrate {
+			index = i + 1
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("bitrate %d kbps is not available at %d Hz: choose from %v", bitrate, enc.Wave.SampleRate, mp3Bitrates[version])
+	}
+
+	var slotsPerFrame = float64(enc.Mpeg.GranulesPerFrame) * mp3.GRANULE_SIZE / float64(enc.Wave.SampleRate) *
+		float64(bitrate) * 1000 / float64(enc.Mpeg.BitsPerSlot)
+
+	enc.Mpeg.Bitrate = int64(bitrate)
+	enc.Mpeg.BitrateIndex = int64(index)
+	enc.Mpeg.WholeSlotsPerFrame = int64(slotsPerFrame)
+	enc.Mpeg.FracSlotsPerFrame = slotsPerFrame - float64(enc.Mpeg.WholeSlotsPerFrame)
+	enc.Mpeg.Slot_lag = -enc.Mpeg.FracSlotsPerFrame
+	if enc.Mpeg.FracSlotsPerFrame == 0 {
+		enc.Mpeg.Padding = 0
+	}
+
+	return nil
+}
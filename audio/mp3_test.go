@@ -0,0 +1,165 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMP3Bitrates(t *testing.T) {
+	var tests = []struct {
+		sampleRate int
+		bitrate    int
+		ok         bool
+	}{
+		{8000, 64, true},
+		{11025, 80, false},
+		{16000, 8, true},
+		{24000, 160, true},
+		{24000, 192, false},
+		{22050, 144, true},
+		{44100, 144, false},
+		{44100, 24, false},
+		{48000, 320, true},
+		{96000, 320, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckMP3Bitrate(tt.bitrate, tt.sampleRate)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckMP3Bitrate(%d, %d) = %v, want ok %v", tt.bitrate, tt.sampleRate, err, tt.ok)
+		}
+
+		// the check agrees with the encoder
+		_, err = NewMP3Encoder(io.Discard, tt.sampleRate, 1, tt.bitrate)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewMP3Encoder at %d Hz and %d kbps = %v, want ok %v", tt.sampleRate, tt.bitrate, err, tt.ok)
+		}
+	}
+
+	for _, sampleRate := range mp3SampleRates {
+		for _, bitrate := range MP3Bitrates(sampleRate) {
+			_, err := NewMP3Encoder(io.Discard, sampleRate, 2, bitrate)
+			if err != nil {
+				t.Errorf("NewMP3Encoder at %d Hz and %d kbps: %v", sampleRate, bitrate, err)
+			}
+		}
+	}
+}
+
+// mp3HeaderRates are the sample rates of the sample rate index by the MPEG version in the header.
+var mp3HeaderRates = map[int][3]int{
+	0: {11025, 12000, 8000},
+	2: {22050, 24000, 16000},
+	3: {44100, 48000, 32000},
+}
+
+// mp3Frame is what a test reads from a frame header.
+type mp3Frame struct {
+	sampleRate, bitrate int
+	padded              bool
+	length              int
+}
+
+// parseMP3Frames splits b into Layer III frames by their headers.
+func parseMP3Frames(t *testing.T, b []byte) []mp3Frame {
+	t.Helper()
+
+	var frames []mp3Frame
+	var pos = 0
+	for pos < len(b) {
+		if pos+4 > len(b) || b[pos] != 0xFF || b[pos+1]&0xE0 != 0xE0 || (b[pos+1]>>1)&3 != 1 {
+			t.Fatalf("no Layer III frame header at %d: % x", pos, b[pos:min(pos+4, len(b))])
+		}
+
+		var version = int(b[pos+1]>>3) & 3
+		var f = mp3Frame{
+			sampleRate: mp3HeaderRates[version][(b[pos+2]>>2)&3],
+			bitrate:    mp3Bitrates[version][b[pos+2]>>4-1],
+			padded:     (b[pos+2]>>1)&1 == 1,
+		}
+		// a frame has 1152 samples in MPEG 1 and 576 in MPEG 2 and 2.5
+		var samples = 576
+		if version == 3 {
+			samples = 1152
+		}
+		f.length = samples / 8 * f.bitrate * 1000 / f.sampleRate
+		if f.padded {
+			f.length++
+		}
+
+		frames = append(frames, f)
+		pos += f.length
+	}
+	if pos != len(b) {
+		t.Fatalf("the last frame ends at %d of %d bytes", pos, len(b))
+	}
+	return frames
+}
+
+func TestMP3Frames(t *testing.T) {
+	var tests = []struct {
+		name       string
+		sampleRate int
+		channels   int
+		bitrate    int
+		duration   time.Duration
+		// frames is the number of frames, the last of which is filled with silence
+		frames int
+		// padded is whether some frames are padded to keep the bitrate
+		padded bool
+	}{
+		{"24 kHz", 24000, 1, 64, 100 * time.Millisecond, 5, false},
+		{"whole frames", 24000, 1, 64, 48 * time.Millisecond, 2, false},
+		{"44.1 kHz", 44100, 2, 128, 2 * time.Second, 77, true},
+		{"resampled to 48 kHz", 44800, 1, 320, 500 * time.Millisecond, 21, false},
+		{"8 kHz", 8000, 1, 8, 100 * time.Millisecond, 2, false},
+		{"22.05 kHz", 22050, 1, 56, time.Second, 39, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewMP3Encoder(&buf, tt.sampleRate, tt.channels, tt.bitrate)
+			if err != nil {
+				t.Fatalf("NewMP3Encoder: %v", err)
+			}
+			// written in two parts, which are encoded without a gap
+			var sound = Silence(tt.sampleRate, tt.channels, tt.duration)
+			var half = sound.Frames() / 2 * tt.channels
+			enc.Write(&PCM{tt.sampleRate, tt.channels, sound.Samples[:half]})
+			enc.Write(&PCM{tt.sampleRate, tt.channels, sound.Samples[half:]})
+			// the end of the last frame is written only once
+			for i := 0; i < 2; i++ {
+				err = enc.Close()
+				if err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			var frames = parseMP3Frames(t, buf.Bytes())
+			if len(frames) != tt.frames {
+				t.Errorf("%d frames, want %d", len(frames), tt.frames)
+			}
+
+			var padded, unpadded int
+			for _, f := range frames {
+				if f.bitrate != tt.bitrate || f.sampleRate != mp3SampleRate(tt.sampleRate) {
+					t.Fatalf("a frame is %d kbps at %d Hz", f.bitrate, f.sampleRate)
+				}
+				if f.padded {
+					padded++
+				} else {
+					unpadded++
+				}
+			}
+			if tt.padded && (padded == 0 || unpadded == 0) {
+				t.Errorf("%d padded and %d unpadded frames, want both", padded, unpadded)
+			}
+			if !tt.padded && padded > 0 {
+				t.Errorf("%d frames are padded, want none", padded)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package audio handles the WAV files synthesized and played by the notifier.
+// Sounds are kept as 16-bit PCM, which VOICEVOX outputs.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xFFFE
+)
+
+// PCM is a 16-bit linear PCM sound. Samples of the channels are interleaved.
+type PCM struct {
+	SampleRate int
+	Channels   int
+	Samples    []int16
+}
+
+// DecodeWAV reads a WAV file of 8, 16, 24 or 32-bit integer PCM or 32-bit float PCM.
+func DecodeWAV(b []byte) (*PCM, error) {
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var format, channels, bits int
+	var sampleRate int
+	var data []byte
+	var hasFormat, hasData bool
+
+	for pos := 12; pos+8 <= len(b) && !hasData; {
+		var id = string(b[pos : pos+4])
+		var size = int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		pos += 8
+
+		// the size of a streamed WAV is not known when the header is written,
+		// and is left as 0 or 0xFFFFFFFF
+		if size < 0 || pos+size > len(b) || id == "data" && size == 0 {
+			size = len(b) - pos
+		}
+		var chunk = b[pos : pos+size]
+
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, errors.New("fmt chunk is too short")
+			}
+			format = int(binary.LittleEndian.Uint16(chunk[0:2]))
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			if format == formatExtensible && len(chunk) >= 26 {
+				format = int(binary.LittleEndian.Uint16(chunk[24:26]))
+			}
+			hasFormat = true
+		case "data":
+			data = chunk
+			hasData = true
+		}
+
+		// chunks are aligned to 2 bytes
+		pos += size + size%2
+	}
+
+	if !hasFormat {
+		return nil, errors.New("fmt chunk was not found")
+	}
+	if !hasData {
+		return nil, errors.New("data chunk was not found")
+	}
+	if channels < 1 || sampleRate < 1 {
+		return nil, fmt.Errorf("invalid format: %d channels, %d Hz", channels, sampleRate)
+	}
+
+	var pcm = &PCM{SampleRate: sampleRate, Channels: channels}
+
+	switch {
+	case format == formatPCM && bits == 8:
+		pcm.Samples = make([]int16, len(data))
+		for i, v := range data {
+			pcm.Samples[i] = int16(int(v)-128) << 8
+		}
+	case format == formatPCM && bits == 16:
+		pcm.Samples = make([]int16, len(data)/2)
+		for i := range pcm.Samples {
+			pcm.Samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+		}
+	case format == formatPCM && bits == 24:
+		pcm.Samples = make([]int16, len(data)/3)
+		for i := range pcm.Samples {
+			pcm.Samples[i] = int16(uint16(data[i*3+1]) | uint16(data[i*3+2])<<8)
+		}
+	case format == formatPCM && bits == 32:
+		pcm.Samples = make([]int16, len(data)/4)
+		for i := range pcm.Samples {
+			pcm.Samples[i] = int16(binary.LittleEndian.Uint32(data[i*4:]) >> 16)
+		}
+	case format == formatFloat && bits == 32:
+		pcm.Samples = make([]int16, len(data)/4)
+		for i := range pcm.Samples {
+			var v = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+			pcm.Samples[i] = clamp(float64(v) * 32767)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: format %d, %d bits", format, bits)
+	}
+
+	// drop an incomplete frame at the end
+	pcm.Samples = pcm.Samples[:len(pcm.Samples)/channels*channels]
+
+	return pcm, nil
+}
+
+// WAV returns p as a 16-bit PCM WAV file.
+func (p *PCM) WAV() []byte {
+	var dataSize = uint32(len(p.Samples) * 2)
+	return append(wavHeader(p.SampleRate, p.Channels, 36+dataSize, dataSize), p.Bytes()...)
+}
+
+// StreamingWAVHeader returns the header of a 16-bit PCM WAV file whose length is not known yet.
+// The samples follow it as returned by PCM.Bytes.
+func StreamingWAVHeader(sampleRate, channels int) []byte {
+	return wavHeader(sampleRate, channels, 0xFFFFFFFF, 0xFFFFFFFF)
+}
+
+func wavHeader(sampleRate, channels int, riffSize, dataSize uint32) []byte {
+	var buf = bytes.NewBuffer(make([]byte, 0, 44))
+
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, riffSize)
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(formatPCM))
+	binary.Write(buf, binary.LittleEndian, uint16(channels))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate*channels*2))
+	binary.Write(buf, binary.LittleEndian, uint16(channels*2))
+	binary.Write(buf, binary.LittleEndian, uint16(16))
+
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, dataSize)
+
+	return buf.Bytes()
+}
+
+// Bytes returns the samples of p in little endian.
+func (p *PCM) Bytes() []byte {
+	var b = make([]byte, len(p.Samples)*2)
+	for i, v := range p.Samples {
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(v))
+	}
+	return b
+}
+
+// Frames returns the number of the samples per channel.
+func (p *PCM) Frames() int {
+	return len(p.Samples) / p.Channels
+}
+
+// Duration returns the length of p.
+func (p *PCM) Duration() time.Duration {
+	return time.Duration(p.Frames()) * time.Second / time.Duration(p.SampleRate)
+}
+
+// Silence returns a silent sound of d.
+func Silence(sampleRate, channels int, d time.Duration) *PCM {
+	var frames = int(d * time.Duration(sampleRate) / time.Second)
+	return &PCM{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Samples:    make([]int16, frames*channels),
+	}
+}
+
+// Concat joins sounds of the same format.
+func Concat(sounds ...*PCM) (*PCM, error) {
+	if len(sounds) == 0 {
+		return nil, errors.New("no sound")
+	}
+
+	var joined = &PCM{SampleRate: sounds[0].SampleRate, Channels: sounds[0].Channels}
+	for _, s := range sounds {
+		if s.SampleRate != joined.SampleRate || s.Channels != joined.Channels {
+			return nil, fmt.Errorf("format mismatch: %d Hz %d ch and %d Hz %d ch", joined.SampleRate, joined.Channels, s.SampleRate, s.Channels)
+		}
+		joined.Samples = append(joined.Samples, s.Samples...)
+	}
+
+	return joined, nil
+}
+
+func clamp(v float64) int16 {
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testWAV returns a WAV of the format whose fmt and data chunks have the sizes.
+func testWAV(format, channels, sampleRate, bits int, dataSize uint32, data []byte) []byte {
+	var b = []byte("RIFF\xff\xff\xff\xffWAVE")
+
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, uint16(format))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, dataSize)
+	return append(b, data...)
+}
+
+func TestDecodeWAV(t *testing.T) {
+	var samples = []byte{0x01, 0x00, 0xff, 0xff, 0x00, 0x80, 0xff, 0x7f}
+	var float = func(vs ...float32) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+		}
+		return b
+	}
+
+	var tests = []struct {
+		name string
+		wav  []byte
+		want *PCM
+	}{
+		{"16 bit", testWAV(formatPCM, 1, 24000, 16, 8, samples), &PCM{24000, 1, []int16{1, -1, -32768, 32767}}},
+		{"stereo", testWAV(formatPCM, 2, 48000, 16, 8, samples), &PCM{48000, 2, []int16{1, -1, -32768, 32767}}},
+		{"streaming with size 0xFFFFFFFF", testWAV(formatPCM, 1, 24000, 16, 0xFFFFFFFF, samples), &PCM{24000, 1, []int16{1, -1, -32768, 32767}}},
+		{"streaming with size 0", testWAV(formatPCM, 1, 24000, 16, 0, samples), &PCM{24000, 1, []int16{1, -1, -32768, 32767}}},
+		{"size over the file", testWAV(formatPCM, 1, 24000, 16, 100, samples), &PCM{24000, 1, []int16{1, -1, -32768, 32767}}},
+		{"shorter size", testWAV(formatPCM, 1, 24000, 16, 4, samples), &PCM{24000, 1, []int16{1, -1}}},
+		{"incomplete frame", testWAV(formatPCM, 2, 24000, 16, 6, samples[:6]), &PCM{24000, 2, []int16{1, -1}}},
+		{"8 bit", testWAV(formatPCM, 1, 8000, 8, 3, []byte{0x80, 0x00, 0xff}), &PCM{8000, 1, []int16{0, -32768, 32512}}},
+		{"24 bit", testWAV(formatPCM, 1, 24000, 24, 6, []byte{0x00, 0x01, 0x00, 0xff, 0xff, 0xff}), &PCM{24000, 1, []int16{1, -1}}},
+		{"32 bit", testWAV(formatPCM, 1, 24000, 32, 4, []byte{0x00, 0x00, 0x01, 0x00}), &PCM{24000, 1, []int16{1}}},
+		{"float", testWAV(formatFloat, 1, 24000, 32, 12, float(0.5, -1, 2)), &PCM{24000, 1, []int16{16384, -32767, 32767}}},
+		{"written by WAV", (&PCM{24000, 1, []int16{1, 2, 3}}).WAV(), &PCM{24000, 1, []int16{1, 2, 3}}},
+		{"written by StreamingWAVHeader", append(StreamingWAVHeader(24000, 2), (&PCM{24000, 2, []int16{1, 2, 3, 4}}).Bytes()...), &PCM{24000, 2, []int16{1, 2, 3, 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeWAV(tt.wav)
+			if err != nil {
+				t.Fatalf("DecodeWAV: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeWAV = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWAVErrors(t *testing.T) {
+	var wav = testWAV(formatPCM, 1, 24000, 16, 2, []byte{0, 0})
+
+	for name, b := range map[string][]byte{
+		"empty":        nil,
+		"not RIFF":     append([]byte("RIFX"), wav[4:]...),
+		"no data":      wav[:36],
+		"no fmt":       append([]byte("RIFF\x00\x00\x00\x00WAVEdata\x02\x00\x00\x00"), 0, 0),
+		"no channels":  testWAV(formatPCM, 0, 24000, 16, 2, []byte{0, 0}),
+		"12 bit":       testWAV(formatPCM, 1, 24000, 12, 2, []byte{0, 0}),
+		"64 bit float": testWAV(formatFloat, 1, 24000, 64, 8, make([]byte, 8)),
+	} {
+		_, err := DecodeWAV(b)
+		if err == nil {
+			t.Errorf("%s: DecodeWAV succeeded", name)
+		}
+	}
+}
+
+func TestConcat(t *testing.T) {
+	var tests = []struct {
+		name   string
+		sounds []*PCM
+		want   *PCM
+	}{
+		{"one", []*PCM{{24000, 1, []int16{1, 2}}}, &PCM{24000, 1, []int16{1, 2}}},
+		{"in order", []*PCM{{24000, 2, []int16{1, 2}}, {24000, 2, nil}, {24000, 2, []int16{3, 4, 5, 6}}}, &PCM{24000, 2, []int16{1, 2, 3, 4, 5, 6}}},
+		{"sample rate mismatch", []*PCM{{24000, 1, nil}, {48000, 1, nil}}, nil},
+		{"channels mismatch", []*PCM{{24000, 1, nil}, {24000, 2, nil}}, nil},
+		{"nothing", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Concat(tt.sounds...)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("Concat succeeded with %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Concat: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Concat = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilence(t *testing.T) {
+	var p = Silence(24000, 2, 50*time.Millisecond)
+	if p.Frames() != 1200 || len(p.Samples) != 2400 || p.Duration() != 50*time.Millisecond {
+		t.Errorf("Silence = %d frames, %d samples, %s", p.Frames(), len(p.Samples), p.Duration())
+	}
+}
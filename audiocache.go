@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/cache"
+)
+
+// defaultCacheMemory is the megabytes of sounds kept in memory when Cache.Memory is not set.
+const defaultCacheMemory = 32
+
+// CacheCommand returns the CommandFunc of "cache", which shows the counters of audioCache.
+func CacheCommand(audioCache *cache.Cache) CommandFunc {
+	return func(args []string) (string, error) {
+		var stats = audioCache.Stats()
+		return fmt.Sprintf(
+			"Hits: %d (memory %d, disk %d), misses: %d, hit ratio: %.1f%%\nMemory: %s, disk: %s",
+			stats.MemoryHits+stats.DiskHits, stats.MemoryHits, stats.DiskHits, stats.Misses, stats.HitRatio()*100,
+			formatBytes(stats.MemoryBytes), formatBytes(stats.DiskBytes),
+		), nil
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
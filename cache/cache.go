@@ -0,0 +1,302 @@
+// Package cache keeps synthesized sounds by the hash of what decided them.
+// Recently used sounds are kept in memory, and the rest on disk, each up to its size limit.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileExt is the extension of the files on disk.
+const fileExt = ".wav"
+
+type Options struct {
+	// MaxMemory is the bytes kept in memory. Nothing is kept in memory if it is 0.
+	MaxMemory int64
+	// Dir is the directory of the disk tier. Nothing is kept on disk if it is empty.
+	Dir string
+	// MaxDisk is the bytes kept in Dir. It is unlimited if it is 0.
+	MaxDisk int64
+}
+
+// Stats are the counters of a cache.
+type Stats struct {
+	MemoryHits  uint64 `json:"memory_hits"`
+	DiskHits    uint64 `json:"disk_hits"`
+	Misses      uint64 `json:"misses"`
+	MemoryBytes int64  `json:"memory_bytes"`
+	DiskBytes   int64  `json:"disk_bytes"`
+}
+
+// HitRatio returns the ratio of the hits in every lookup, or 0 if nothing was looked up.
+func (s Stats) HitRatio() float64 {
+	var hits = s.MemoryHits + s.DiskHits
+	if hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+s.Misses)
+}
+
+// Cache is a two tier LRU cache. It is safe for concurrent use.
+type Cache struct {
+	options Options
+
+	mu     sync.Mutex
+	memory *tier
+	disk   *tier
+
+	memoryHits, diskHits, misses uint64
+}
+
+// tier is an LRU list of keys with their sizes.
+// The memory tier keeps the data as well.
+type tier struct {
+	max   int64
+	size  int64
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	size int64
+	data []byte
+}
+
+func newTier(max int64) *tier {
+	return &tier{
+		max:   max,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// add puts e at the front and returns the entries evicted to keep the size.
+// e itself is evicted if it is larger than the limit.
+func (t *tier) add(e *entry) []*entry {
+	if elem, ok := t.items[e.key]; ok {
+		t.size -= elem.Value.(*entry).size
+		t.order.Remove(elem)
+	}
+
+	t.items[e.key] = t.order.PushFront(e)
+	t.size += e.size
+
+	var evicted []*entry
+	for t.max > 0 && t.size > t.max {
+		var oldest = t.order.Back()
+		var old = t.order.Remove(oldest).(*entry)
+		delete(t.items, old.key)
+		t.size -= old.size
+		evicted = append(evicted, old)
+	}
+	return evicted
+}
+
+func (t *tier) get(key string) (*entry, bool) {
+	elem, ok := t.items[key]
+	if !ok {
+		return nil, false
+	}
+	t.order.MoveToFront(elem)
+	return elem.Value.(*entry), true
+}
+
+func (t *tier) remove(key string) {
+	if elem, ok := t.items[key]; ok {
+		t.size -= elem.Value.(*entry).size
+		t.order.Remove(elem)
+		delete(t.items, key)
+	}
+}
+
+// Key returns the key of the parts, which is the hex SHA-256 of them.
+func Key(parts ...string) string {
+	var h = sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// New returns a cache of options. The files already in Dir are used from the least recently modified.
+func New(options Options) (*Cache, error) {
+	var c = &Cache{options: options}
+
+	if options.MaxMemory > 0 {
+		c.memory = newTier(options.MaxMemory)
+	}
+
+	if options.Dir == "" {
+		return c, nil
+	}
+
+	err := os.MkdirAll(options.Dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("MkdirAll: %v", err)
+	}
+
+	files, err := os.ReadDir(options.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadDir: %v", err)
+	}
+
+	type diskFile struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var found []diskFile
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		// a temporary file left by a crash
+		if strings.HasPrefix(f.Name(), ".") {
+			os.Remove(filepath.Join(options.Dir, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, diskFile{key: strings.TrimSuffix(f.Name(), fileExt), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.Before(found[j].modTime) })
+
+	c.disk = newTier(options.MaxDisk)
+	for _, f := range found {
+		c.removeFiles(c.disk.add(&entry{key: f.key, size: f.size}))
+	}
+
+	return c, nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.options.Dir, key+fileExt)
+}
+
+func (c *Cache) removeFiles(evicted []*entry) {
+	for _, e := range evicted {
+		os.Remove(c.path(e.key))
+	}
+}
+
+// Get returns the data of key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.memory != nil {
+		if e, ok := c.memory.get(key); ok {
+			c.memoryHits++
+			return e.data, true
+		}
+	}
+
+	if c.disk != nil {
+		if _, ok := c.disk.get(key); ok {
+			data, err := os.ReadFile(c.path(key))
+			if err == nil {
+				c.diskHits++
+				// the modification time keeps the order after a restart
+				now := time.Now()
+				os.Chtimes(c.path(key), now, now)
+				if c.memory != nil {
+					c.memory.add(&entry{key: key, size: int64(len(data)), data: data})
+				}
+				return data, true
+			}
+			c.disk.remove(key)
+		}
+	}
+
+	c.misses++
+	return nil, false
+}
+
+// Has returns whether key is in either tier, without counting it as a lookup or marking it as used.
+func (c *Cache) Has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.memory != nil {
+		if _, ok := c.memory.items[key]; ok {
+			return true
+		}
+	}
+	if c.disk != nil {
+		if _, ok := c.disk.items[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Put stores data as key in both tiers.
+// A failure to write the file is returned, but the data is still kept in memory.
+func (c *Cache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var size = int64(len(data))
+
+	if c.memory != nil {
+		c.memory.add(&entry{key: key, size: size, data: data})
+	}
+
+	if c.disk == nil {
+		return nil
+	}
+	if _, ok := c.disk.items[key]; ok {
+		return nil
+	}
+
+	// write to a temporary file and rename it, so that a half written file is never read
+	tmp, err := os.CreateTemp(c.options.Dir, ".*"+fileExt)
+	if err != nil {
+		return fmt.Errorf("CreateTemp: %v", err)
+	}
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Write: %v", err)
+	}
+	err = os.Rename(tmp.Name(), c.path(key))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Rename: %v", err)
+	}
+
+	c.removeFiles(c.disk.add(&entry{key: key, size: size}))
+	return nil
+}
+
+// Stats returns the counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stats = Stats{
+		MemoryHits: c.memoryHits,
+		DiskHits:   c.diskHits,
+		Misses:     c.misses,
+	}
+	if c.memory != nil {
+		stats.MemoryBytes = c.memory.size
+	}
+	if c.disk != nil {
+		stats.DiskBytes = c.disk.size
+	}
+	return stats
+}
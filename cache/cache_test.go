@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testData returns 10 bytes of key.
+func testData(key string) []byte {
+	return bytes.Repeat([]byte(key), 10)[:10]
+}
+
+func tierKeys(t *tier) string {
+	if t == nil {
+		return ""
+	}
+	var keys []string
+	for elem := t.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*entry).key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func fileKeys(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	var keys []string
+	for _, f := range files {
+		keys = append(keys, strings.TrimSuffix(filepath.Base(f), fileExt))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func TestCache(t *testing.T) {
+	var tests = []struct {
+		name    string
+		options Options
+		// steps are "put <key>" and "get <key>" with 10 bytes of data
+		steps []string
+		// memory and disk are the keys in each tier, the most recently used first
+		memory, disk string
+		// files are the keys on disk, sorted
+		files string
+		stats Stats
+	}{
+		{
+			name:    "memory eviction",
+			options: Options{MaxMemory: 25},
+			steps:   []string{"put a", "put b", "put c", "get a"},
+			memory:  "c,b",
+			stats:   Stats{Misses: 1, MemoryBytes: 20},
+		},
+		{
+			name:    "memory keeps the recently used",
+			options: Options{MaxMemory: 25},
+			steps:   []string{"put a", "put b", "get a", "put c", "get a"},
+			memory:  "a,c",
+			stats:   Stats{MemoryHits: 2, MemoryBytes: 20},
+		},
+		{
+			name:    "larger than memory",
+			options: Options{MaxMemory: 5},
+			steps:   []string{"put a", "get a"},
+			stats:   Stats{Misses: 1},
+		},
+		{
+			name:    "disk eviction",
+			options: Options{MaxDisk: 25},
+			steps:   []string{"put a", "put b", "put c", "get a"},
+			disk:    "c,b",
+			files:   "b,c",
+			stats:   Stats{Misses: 1, DiskBytes: 20},
+		},
+		{
+			name:    "disk keeps the recently used",
+			options: Options{MaxDisk: 25},
+			steps:   []string{"put a", "put b", "get a", "put c"},
+			disk:    "c,a",
+			files:   "a,c",
+			stats:   Stats{DiskHits: 1, DiskBytes: 20},
+		},
+		{
+			name:    "unlimited disk",
+			options: Options{MaxMemory: 10},
+			steps:   []string{"put a", "put b", "put c"},
+			memory:  "c",
+			disk:    "c,b,a",
+			files:   "a,b,c",
+			stats:   Stats{MemoryBytes: 10, DiskBytes: 30},
+		},
+		{
+			name:    "promotion from disk to memory",
+			options: Options{MaxMemory: 15, MaxDisk: 100},
+			steps:   []string{"put a", "put b", "get a", "get a"},
+			memory:  "a",
+			disk:    "a,b",
+			files:   "a,b",
+			stats:   Stats{MemoryHits: 1, DiskHits: 1, MemoryBytes: 10, DiskBytes: 20},
+		},
+		{
+			name:    "put again",
+			options: Options{MaxMemory: 100, MaxDisk: 100},
+			steps:   []string{"put a", "put b", "put a"},
+			memory:  "a,b",
+			disk:    "b,a",
+			files:   "a,b",
+			stats:   Stats{MemoryBytes: 20, DiskBytes: 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir string
+			if tt.options.MaxDisk > 0 || tt.disk != "" {
+				dir = t.TempDir()
+				tt.options.Dir = dir
+			}
+			c, err := New(tt.options)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			for _, step := range tt.steps {
+				op, key, _ := strings.Cut(step, " ")
+				switch op {
+				case "put":
+					err := c.Put(key, testData(key))
+					if err != nil {
+						t.Fatalf("%s: %v", step, err)
+					}
+				case "get":
+					data, ok := c.Get(key)
+					if ok && !bytes.Equal(data, testData(key)) {
+						t.Errorf("%s = %q", step, data)
+					}
+				}
+			}
+
+			if got := tierKeys(c.memory); got != tt.memory {
+				t.Errorf("memory = %q, want %q", got, tt.memory)
+			}
+			if got := tierKeys(c.disk); got != tt.disk {
+				t.Errorf("disk = %q, want %q", got, tt.disk)
+			}
+			if got := fileKeys(dir); got != tt.files {
+				t.Errorf("files = %q, want %q", got, tt.files)
+			}
+			if got := c.Stats(); got != tt.stats {
+				t.Errorf("Stats = %+v, want %+v", got, tt.stats)
+			}
+		})
+	}
+}
+
+func TestCacheReopen(t *testing.T) {
+	var dir = t.TempDir()
+
+	c, err := New(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		err := c.Put(key, testData(key))
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	// a is the most recently used, then c and b
+	var now = time.Now()
+	for i, key := range []string{"b", "c", "a"} {
+		var mtime = now.Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(filepath.Join(dir, key+fileExt), mtime, mtime)
+	}
+	// a temporary file left by a crash
+	os.WriteFile(filepath.Join(dir, ".tmp"+fileExt), []byte("half"), 0644)
+
+	var tests = []struct {
+		name    string
+		maxDisk int64
+		disk    string
+	}{
+		{"every entry", 0, "a,c,b"},
+		{"the least recently used are removed", 25, "a,c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(Options{MaxMemory: 100, Dir: dir, MaxDisk: tt.maxDisk})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			if got := tierKeys(c.disk); got != tt.disk {
+				t.Errorf("disk = %q, want %q", got, tt.disk)
+			}
+			if _, err := os.Stat(filepath.Join(dir, ".tmp"+fileExt)); !os.IsNotExist(err) {
+				t.Error("the temporary file was not removed")
+			}
+
+			data, ok := c.Get("a")
+			if !ok || !bytes.Equal(data, testData("a")) {
+				t.Errorf("Get(a) = %q, %v after reopening", data, ok)
+			}
+			if stats := c.Stats(); stats.DiskHits != 1 {
+				t.Errorf("DiskHits = %d, want 1", stats.DiskHits)
+			}
+		})
+	}
+
+	if got := fileKeys(dir); got != "a,c" {
+		t.Errorf("files = %q, want a,c", got)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cast"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+const (
+	heartbeatNamespace = "urn:x-cast:com.google.cast.tp.heartbeat"
+
+	// heartbeatInterval is how often the device is pinged
+	heartbeatInterval = 5 * time.Second
+	// heartbeatTimeout is how long the device may be silent before the connection is dropped
+	heartbeatTimeout = 3 * heartbeatInterval
+	// statusInterval is how often the status is asked for,
+	// in case the device does not tell every change by itself
+	statusInterval = 30 * time.Second
+	// the wait before a reconnection doubles on every failure up to maxReconnectWait
+	minReconnectWait = heartbeatInterval
+	maxReconnectWait = 5 * time.Minute
+)
+
+var errConnectionClosed = errors.New("the connection was closed")
+
+// DeviceStatus is what a device is doing, as it last told us.
+type DeviceStatus struct {
+	Device string `json:"device"`
+	Online bool   `json:"online"`
+	// Idle is true when no app is running other than the idle screen
+	Idle bool `json:"idle"`
+	// App is the name of the running app
+	App string `json:"app,omitempty"`
+	// PlayerState is the state of the media, such as PLAYING, PAUSED and BUFFERING
+	PlayerState string  `json:"player_state,omitempty"`
+	Volume      float32 `json:"volume"`
+	Muted       bool    `json:"muted"`
+	// Error is why the device is offline
+	Error string `json:"error,omitempty"`
+	// LastSeen is when the device sent a message last time
+	LastSeen time.Time `json:"last_seen,omitempty"`
+}
+
+func (s DeviceStatus) Playing() bool {
+	return s.PlayerState == "PLAYING" || s.PlayerState == "BUFFERING"
+}
+
+func (s DeviceStatus) String() string {
+	if !s.Online {
+		if s.Error != "" {
+			return fmt.Sprintf("%s: offline (%s)", s.Device, s.Error)
+		}
+		return fmt.Sprintf("%s: offline", s.Device)
+	}
+
+	var state string
+	switch {
+	case s.Idle:
+		state = "idle"
+	case s.Playing():
+		state = fmt.Sprintf("playing on %s", s.App)
+	default:
+		state = fmt.Sprintf("%s is open", s.App)
+	}
+
+	var volume = fmt.Sprintf("volume %.2f", s.Volume)
+	if s.Muted {
+		volume += " (muted)"
+	}
+
+	return fmt.Sprintf("%s: online, %s, %s", s.Device, state, volume)
+}
+
+// CastConnection keeps a connection to a device open between messages.
+// Once started, it pings the device, drops the connection when the device stops answering,
+// and connects again with a growing wait. The status of the device is kept from its messages.
+type CastConnection struct {
+	name     string
+	resolver *DeviceResolver
+	options  []application.ApplicationOption
+
+	// mu is held while the app is used, so that a message and the monitor do not talk at once
+	mu   sync.Mutex
+	app  *application.Application
+	conn *cast.Connection
+	addr string
+	// retryAt is when the monitor may connect again after a failure
+	retryAt   time.Time
+	backoff   time.Duration
+	updatedAt time.Time
+	// closed is set by Close, after which the device is not connected any more
+	closed bool
+
+	statusMu sync.Mutex
+	status   DeviceStatus
+}
+
+func NewCastConnection(settings GoogleHomeSetting, resolver *DeviceResolver) (*CastConnection, error) {
+	options := []application.ApplicationOption{
+		application.WithDebug(DEBUG),
+		// failures are retried by the monitor instead
+		application.WithConnectionRetries(1),
+	}
+
+	if settings.Iface != "" {
+		iface, err := net.InterfaceByName(settings.Iface)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find interface %q: %v", settings.Iface, err)
+		}
+		options = append(options, application.WithIface(iface))
+	}
+
+	return &CastConnection{
+		name:     settings.Name,
+		resolver: resolver,
+		options:  options,
+		status:   DeviceStatus{Device: settings.Name},
+	}, nil
+}
+
+// Start starts the monitor, which connects to the device right away
+// and keeps the connection until ctx is done.
+func (c *CastConnection) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			c.check()
+
+			select {
+			case <-ctx.Done():
+				c.mu.Lock()
+				if c.app != nil {
+					c.disconnect(ctx.Err())
+				}
+				c.mu.Unlock()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Do runs f with the app connected to the device, connecting first if needed.
+// The status is updated before f, so that f sees what the device is doing now.
+func (c *CastConnection) Do(f func(app *application.Application, addr string) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errConnectionClosed
+	}
+
+	if c.app != nil {
+		err := c.update()
+		if err != nil {
+			c.disconnect(err)
+		}
+	}
+
+	if c.app == nil {
+		err := c.connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	return f(c.app, c.addr)
+}
+
+// Close disconnects from the device, waiting for the message being played.
+// The device is not connected again.
+func (c *CastConnection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.app != nil {
+		c.disconnect(errConnectionClosed)
+	}
+}
+
+// Status returns the last known status of the device.
+func (c *CastConnection) Status() DeviceStatus {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.status
+}
+
+// check is run by the monitor on every heartbeat.
+func (c *CastConnection) check() {
+	// a message is being played, which tells whether the device is alive anyway
+	if !c.mu.TryLock() {
+		return
+	}
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	if c.app == nil {
+		if time.Now().Before(c.retryAt) {
+			return
+		}
+		err := c.connect()
+		if err != nil {
+			slog.Warn("Failed to connect to the device", "device", c.name, "retry_in", c.backoff, "error", err)
+			deviceErrors.Inc(c.name, "connection")
+		}
+		return
+	}
+
+	err := c.conn.Send(-1, &cast.PayloadHeader{Type: "PING"}, "sender-0", "receiver-0", heartbeatNamespace)
+	if err == nil && time.Since(c.lastSeen()) > heartbeatTimeout {
+		err = fmt.Errorf("the device stopped answering")
+	}
+	if err == nil && time.Since(c.updatedAt) >= statusInterval {
+		err = c.update()
+	}
+	if err != nil {
+		slog.Warn("Lost the connection to the device", "device", c.name, "error", err)
+		deviceErrors.Inc(c.name, "connection")
+		c.disconnect(err)
+	}
+}
+
+// connect starts a new app. c.mu must be held.
+func (c *CastConnection) connect() error {
+	var conn *cast.Connection
+	app, addr, err := startApplication(c.resolver, func() *application.Application {
+		conn = cast.NewConnection()
+		app := application.NewApplication(append(c.options, application.WithConnection(conn))...)
+		app.AddMessageFunc(c.handleMessage)
+		return app
+	})
+	if err != nil {
+		c.backoff *= 2
+		if c.backoff < minReconnectWait {
+			c.backoff = minReconnectWait
+		}
+		if c.backoff > maxReconnectWait {
+			c.backoff = maxReconnectWait
+		}
+		c.retryAt = time.Now().Add(c.backoff)
+
+		c.statusMu.Lock()
+		c.status.Online = false
+		c.status.Error = err.Error()
+		c.statusMu.Unlock()
+		return err
+	}
+
+	c.app, c.conn, c.addr = app, conn, addr
+	c.backoff = 0
+	c.updatedAt = time.Now()
+
+	c.statusMu.Lock()
+	c.status.Online = true
+	c.status.Error = ""
+	c.status.LastSeen = time.Now()
+	c.statusMu.Unlock()
+
+	return nil
+}
+
+// disconnect closes the app. c.mu must be held.
+// The monitor connects again on the next heartbeat.
+func (c *CastConnection) disconnect(err error) {
+	c.app.Close(false)
+	c.app, c.conn = nil, nil
+	c.retryAt = time.Time{}
+
+	c.statusMu.Lock()
+	c.status.Online = false
+	c.status.Error = err.Error()
+	c.statusMu.Unlock()
+}
+
+// update asks the device for the status. c.mu must be held.
+func (c *CastConnection) update() error {
+	err := c.app.Update()
+	if err != nil {
+		return fmt.Errorf("Update: %v", err)
+	}
+	c.updatedAt = time.Now()
+	return nil
+}
+
+func (c *CastConnection) lastSeen() time.Time {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.status.LastSeen
+}
+
+// handleMessage keeps the status from the messages of the device,
+// including the answers to the requests of the app.
+func (c *CastConnection) handleMessage(msg *pb.CastMessage) {
+	var payload = []byte(msg.GetPayloadUtf8())
+
+	var header cast.PayloadHeader
+	if json.Unmarshal(payload, &header) != nil {
+		return
+	}
+
+	c.statusMu.Lock()
+	c.status.LastSeen = time.Now()
+	c.statusMu.Unlock()
+
+	switch header.Type {
+	case "RECEIVER_STATUS":
+		var resp cast.ReceiverStatusResponse
+		if json.Unmarshal(payload, &resp) != nil {
+			return
+		}
+		var app *cast.Application
+		for i := range resp.Status.Applications {
+			app = &resp.Status.Applications[i]
+		}
+		c.setReceiverStatus(app, resp.Status.Volume)
+	case "MEDIA_STATUS":
+		var resp cast.MediaStatusResponse
+		if json.Unmarshal(payload, &resp) != nil {
+			return
+		}
+		var state string
+		for _, media := range resp.Status {
+			state = media.PlayerState
+		}
+		c.statusMu.Lock()
+		c.status.PlayerState = state
+		c.statusMu.Unlock()
+	}
+}
+
+// setReceiverStatus sets the running app and the volume.
+func (c *CastConnection) setReceiverStatus(app *cast.Application, volume cast.Volume) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.status.Volume = volume.Level
+	c.status.Muted = volume.Muted
+
+	if app == nil || app.IsIdleScreen || app.AppId == backdropAppID {
+		c.status.Idle = true
+		c.status.App = ""
+		c.status.PlayerState = ""
+		return
+	}
+
+	if c.status.App != app.DisplayName {
+		c.status.PlayerState = ""
+	}
+	c.status.Idle = false
+	c.status.App = app.DisplayName
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/audio"
+)
+
+// Chimes are the sounds played before the speech, by priority.
+type Chimes struct {
+	sounds map[Priority]*audio.PCM
+	gap    time.Duration
+}
+
+// NewChimes reads the chime files in settings.
+func NewChimes(settings ChimeSetting) (*Chimes, error) {
+	if settings.Gap < 0 {
+		return nil, fmt.Errorf("Gap must not be negative")
+	}
+
+	var c = &Chimes{
+		sounds: map[Priority]*audio.PCM{},
+		gap:    time.Duration(settings.Gap * float32(time.Second)),
+	}
+
+	for priority, path := range map[Priority]string{
+		PriorityNormal: settings.Normal,
+		PriorityUrgent: settings.Urgent,
+	} {
+		if path == "" {
+			continue
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ReadFile: %v", err)
+		}
+
+		sound, err := audio.DecodeWAV(b)
+		if err != nil {
+			return nil, fmt.Errorf("DecodeWAV: %s: %v", path, err)
+		}
+
+		c.sounds[priority] = sound
+	}
+
+	return c, nil
+}
+
+// Prepend returns speech after the chime of priority.
+// The chime is converted to the sample rate and the channels of speech.
+// speech is returned as it is if there is no chime for priority.
+func (c *Chimes) Prepend(priority Priority, speech *audio.PCM) (*audio.PCM, error) {
+	if c == nil || c.sounds[priority] == nil {
+		return speech, nil
+	}
+
+	var chime = c.sounds[priority].Convert(speech.SampleRate, speech.Channels)
+	var gap = audio.Silence(speech.SampleRate, speech.Channels, c.gap)
+
+	return audio.Concat(chime, gap, speech)
+}
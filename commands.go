@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+)
+
+// CommandFunc runs a command given to the bot, such as "dict list",
+// and returns the text to answer. args do not include the command name.
+type CommandFunc func(args []string) (string, error)
+
+// Commands are the commands by their names.
+// Inputs check them before taking a text as a message.
+type Commands map[string]CommandFunc
+
+// Run runs the command named by the first word of text.
+// ok is false if text is not a command.
+func (c Commands) Run(text string) (answer string, ok bool, err error) {
+	var fields = strings.Fields(text)
+	if len(fields) == 0 {
+		return "", false, nil
+	}
+
+	command, ok := c[fields[0]]
+	if !ok {
+		return "", false, nil
+	}
+
+	answer, err = command(fields[1:])
+	return answer, true, err
+}
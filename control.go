@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxQueueListText is the number of characters of a text shown by the queue command.
+const maxQueueListText = 30
+
+// Controller lets the inputs control the messages.
+// It stops the message being spoken, cancels waiting ones,
+// and keeps the default voice and volume changed by the commands.
+type Controller struct {
+	queue *MessageQueue
+	// finish reports the result of a canceled message
+	finish func(*Message, PlayReport, error)
+
+	mu      sync.Mutex
+	current *Message
+	stop    context.CancelFunc
+	// canceled is the ID of the message taken off the queue and canceled before Begin
+	canceled string
+	voice    string
+	volume   *float32
+}
+
+func NewController(queue *MessageQueue, finish func(*Message, PlayReport, error)) *Controller {
+	return &Controller{
+		queue:  queue,
+		finish: finish,
+	}
+}
+
+// Begin marks m as being spoken. The returned context is canceled when m is stopped,
+// and is already canceled if m was canceled after it was taken off the queue.
+func (c *Controller) Begin(m *Message) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.current, c.stop = m, cancel
+	if c.canceled == m.ID {
+		c.canceled = ""
+		cancel()
+	}
+	return ctx
+}
+
+// End marks m as spoken.
+func (c *Controller) End(m *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == m {
+		c.stop()
+		c.current, c.stop = nil, nil
+	}
+}
+
+// Stop stops the message being spoken. It returns false if there is none.
+func (c *Controller) Stop() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		return false
+	}
+	c.stop()
+	return true
+}
+
+// Cancel stops the message of id if it is being spoken, or takes it off the queue.
+// It returns false if the message is neither.
+func (c *Controller) Cancel(id string) bool {
+	c.mu.Lock()
+	if c.current != nil && c.current.ID == id {
+		c.stop()
+		c.mu.Unlock()
+		return true
+	}
+	c.mu.Unlock()
+
+	m := c.queue.Remove(id)
+	if m != nil {
+		c.finish(m, nil, ErrCanceled)
+		return true
+	}
+
+	// the message may have been taken off the queue and not begun yet
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil && c.current.ID == id {
+		c.stop()
+		return true
+	}
+	if processing := c.queue.Processing(); processing != nil && processing.ID == id {
+		c.canceled = id
+		return true
+	}
+	return false
+}
+
+// Options returns options with the defaults set by the commands.
+func (c *Controller) Options(options MessageOptions) MessageOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if options.Voice == "" {
+		options.Voice = c.voice
+	}
+	if options.Volume == nil && c.volume != nil {
+		var v = *c.volume
+		options.Volume = &v
+	}
+	return options
+}
+
+// StopCommand is the CommandFunc of "stop".
+func (c *Controller) StopCommand(args []string) (string, error) {
+	if !c.Stop() {
+		return "Nothing is being spoken.", nil
+	}
+	return "Stopped the message.", nil
+}
+
+// VolumeCommand is the CommandFunc of "volume".
+//
+//	volume          shows the default volume
+//	volume 0.4      sets the volume of every device
+//	volume reset    goes back to Volume of each device
+func (c *Controller) VolumeCommand(args []string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(args) == 0 {
+		if c.volume == nil {
+			return "The volume is Volume of each device.", nil
+		}
+		return fmt.Sprintf("The volume is %.2f.", *c.volume), nil
+	}
+
+	if args[0] == "reset" {
+		c.volume = nil
+		return "The volume is back to Volume of each device.", nil
+	}
+
+	volume, err := strconv.ParseFloat(args[0], 32)
+	if err != nil || volume < 0 || volume > 1 {
+		return "", fmt.Errorf("volume must be between 0 and 1")
+	}
+	var v = float32(volume)
+	c.volume = &v
+
+	return fmt.Sprintf("The volume is set to %.2f.", v), nil
+}
+
+// VoiceCommand is the CommandFunc of "voice".
+//
+//	voice              shows the default voice
+//	voice ずんだもん     sets the default voice
+//	voice reset        goes back to the voice in settings
+func (c *Controller) VoiceCommand(args []string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(args) == 0 {
+		if c.voice == "" {
+			return "The voice is the default of the engine.", nil
+		}
+		return fmt.Sprintf("The voice is %s.", c.voice), nil
+	}
+
+	if args[0] == "reset" {
+		c.voice = ""
+		return "The voice is back to the default of the engine.", nil
+	}
+
+	c.voice = strings.Join(args, " ")
+	return fmt.Sprintf("The voice is set to %s.", c.voice), nil
+}
+
+// QueueCommand is the CommandFunc of "queue", which lists the message being spoken and the waiting ones.
+func (c *Controller) QueueCommand(args []string) (string, error) {
+	var messages = c.queue.Messages()
+	if len(messages) == 0 {
+		return "The queue is empty.", nil
+	}
+
+	c.mu.Lock()
+	var current = c.current
+	c.mu.Unlock()
+
+	var lines []string
+	for i, m := range messages {
+		var text = []rune(m.Text)
+		if len(text) > maxQueueListText {
+			text = append(text[:maxQueueListText], '…')
+		}
+
+		var line = fmt.Sprintf("%d. [%s] %s", i+1, m.ID, string(text))
+		if m.Options.Priority != PriorityNormal {
+			line += fmt.Sprintf(" (%s)", m.Options.Priority)
+		}
+		if m == current {
+			line += " (speaking)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestControllerCancel(t *testing.T) {
+	queue, err := NewMessageQueue(QueueSetting{})
+	if err != nil {
+		t.Fatalf("NewMessageQueue: %v", err)
+	}
+
+	var finished []error
+	var controller = NewController(queue, func(m *Message, report PlayReport, err error) {
+		finished = append(finished, err)
+	})
+
+	var first = NewMessage("test", "first", MessageOptions{})
+	var second = NewMessage("test", "second", MessageOptions{})
+	queue.Push(first)
+	queue.Push(second)
+
+	// waiting
+	if !controller.Cancel(second.ID) {
+		t.Error("Cancel of a waiting message = false")
+	}
+	if len(finished) != 1 || finished[0] != ErrCanceled {
+		t.Errorf("finished = %v, want ErrCanceled", finished)
+	}
+
+	// taken off the queue but not begun yet
+	if m := queue.Pop(context.Background()); m != first {
+		t.Fatalf("Pop = %v, want the first message", m)
+	}
+	if !controller.Cancel(first.ID) {
+		t.Error("Cancel of a message between Pop and Begin = false")
+	}
+	var ctx = controller.Begin(first)
+	if ctx.Err() == nil {
+		t.Error("the context of a message canceled before Begin is not canceled")
+	}
+	controller.End(first)
+	queue.Done(first)
+
+	// being spoken
+	var third = NewMessage("test", "third", MessageOptions{})
+	queue.Push(third)
+	queue.Pop(context.Background())
+	ctx = controller.Begin(third)
+	if ctx.Err() != nil {
+		t.Error("the context of the next message is canceled")
+	}
+	if !controller.Cancel(third.ID) {
+		t.Error("Cancel of the message being spoken = false")
+	}
+	if ctx.Err() == nil {
+		t.Error("the context of the canceled message is not canceled")
+	}
+	controller.End(third)
+	queue.Done(third)
+
+	// already spoken
+	if controller.Cancel(third.ID) {
+		t.Error("Cancel of a spoken message = true")
+	}
+	if len(finished) != 1 {
+		t.Errorf("finish was called %d times, want only for the waiting message", len(finished))
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TargetAll is the target which means every device
+	TargetAll = "all"
+
+	defaultDeviceName = "default"
+)
+
+// GoogleHome is a named device.
+type GoogleHome struct {
+	Name     string
+	settings GoogleHomeSetting
+	conn     *CastConnection
+}
+
+func NewGoogleHome(settings GoogleHomeSetting) (*GoogleHome, error) {
+	switch settings.playMode() {
+	case PlayModeInterrupt, PlayModeWait, PlayModeDuck:
+	default:
+		return nil, fmt.Errorf("Unknown mode %q", settings.Mode)
+	}
+
+	if settings.Detach || settings.ForceDetach {
+		slog.Warn("Detach and ForceDetach are no longer used, since the media is always detached", "device", settings.Name)
+	}
+
+	discoverer, err := newDiscoverer(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := NewCastConnection(settings, NewDeviceResolver(settings, discoverer))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoogleHome{
+		Name:     settings.Name,
+		settings: settings,
+		conn:     conn,
+	}, nil
+}
+
+func (g *GoogleHome) Play(ctx context.Context, media *MediaFile, options MessageOptions) error {
+	var settings = g.settings
+	if options.Volume != nil {
+		settings.Volume = *options.Volume
+	}
+	return Play(ctx, media, settings, g.conn)
+}
+
+// GoogleHomes holds every device and group in settings.
+type GoogleHomes struct {
+	devices       map[string]*GoogleHome
+	names         []string
+	groups        map[string][]string
+	defaultTarget string
+}
+
+func NewGoogleHomes(settings *Setting) (*GoogleHomes, error) {
+	var homes = &GoogleHomes{
+		devices:       map[string]*GoogleHome{},
+		groups:        settings.GoogleHomeGroups,
+		defaultTarget: settings.DefaultTarget,
+	}
+
+	if homes.defaultTarget == "" {
+		homes.defaultTarget = TargetAll
+	}
+
+	for _, s := range settings.GoogleHomeSettings() {
+		if s.Name == "" {
+			return nil, fmt.Errorf("Name of a GoogleHomes entry is empty")
+		}
+		if _, ok := homes.devices[s.Name]; ok {
+			return nil, fmt.Errorf("Device %q is defined twice", s.Name)
+		}
+		if _, ok := homes.groups[s.Name]; ok || s.Name == TargetAll {
+			return nil, fmt.Errorf("Device name %q conflicts with a group", s.Name)
+		}
+
+		home, err := NewGoogleHome(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Name, err)
+		}
+
+		homes.devices[s.Name] = home
+		homes.names = append(homes.names, s.Name)
+	}
+
+	for group, members := range homes.groups {
+		for _, member := range members {
+			if _, ok := homes.devices[member]; !ok {
+				return nil, fmt.Errorf("Group %q contains unknown device %q", group, member)
+			}
+		}
+	}
+
+	// check the default target here, rather than on every message
+	if _, err := homes.Lookup(""); err != nil {
+		return nil, fmt.Errorf("DefaultTarget: %v", err)
+	}
+
+	return homes, nil
+}
+
+// Start keeps the connection to every device until ctx is done.
+func (h *GoogleHomes) Start(ctx context.Context) {
+	for _, n := range h.names {
+		h.devices[n].conn.Start(ctx)
+	}
+}
+
+// Close disconnects from every device after the messages being played.
+func (h *GoogleHomes) Close() {
+	for _, n := range h.names {
+		h.devices[n].conn.Close()
+	}
+}
+
+// Status returns the status of every device.
+func (h *GoogleHomes) Status() []DeviceStatus {
+	var statuses []DeviceStatus
+	for _, n := range h.names {
+		statuses = append(statuses, h.devices[n].conn.Status())
+	}
+	return statuses
+}
+
+// StatusCommand is the CommandFunc of "status", which answers the status of every device.
+func (h *GoogleHomes) StatusCommand(args []string) (string, error) {
+	var lines []string
+	for _, status := range h.Status() {
+		lines = append(lines, status.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Lookup returns the devices of target.
+// target is a comma separated list of device names, group names, or "all".
+// The default target is used when target is empty.
+func (h *GoogleHomes) Lookup(target string) ([]*GoogleHome, error) {
+	if target == "" {
+		target = h.defaultTarget
+	}
+
+	var found = map[string]bool{}
+	for _, name := range strings.Split(target, ",") {
+		name = strings.TrimSpace(name)
+
+		switch {
+		case name == TargetAll:
+			for _, n := range h.names {
+				found[n] = true
+			}
+		case h.devices[name] != nil:
+			found[name] = true
+		case h.groups[name] != nil:
+			for _, n := range h.groups[name] {
+				found[n] = true
+			}
+		default:
+			return nil, fmt.Errorf("Unknown device or group %q", name)
+		}
+	}
+
+	var homes []*GoogleHome
+	for _, n := range h.names {
+		if found[n] {
+			homes = append(homes, h.devices[n])
+		}
+	}
+
+	return homes, nil
+}
+
+// MaxDuration returns the shortest MaxDuration of the devices of target, or 0 if none is limited.
+// A message is cut at a sentence boundary to fit it.
+func (h *GoogleHomes) MaxDuration(target string) time.Duration {
+	homes, err := h.Lookup(target)
+	if err != nil {
+		return 0
+	}
+
+	var shortest time.Duration
+	for _, home := range homes {
+		var d = home.settings.maxDuration()
+		if d > 0 && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	return shortest
+}
+
+// Play plays media on every device of options.Target at the same time.
+// Canceling ctx stops the media on every device.
+func (h *GoogleHomes) Play(ctx context.Context, media *MediaFile, options MessageOptions) (PlayReport, error) {
+	homes, err := h.Lookup(options.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	var report = make(PlayReport, len(homes))
+	var wg sync.WaitGroup
+	for i, home := range homes {
+		wg.Add(1)
+		go func(i int, home *GoogleHome) {
+			defer wg.Done()
+			var start = time.Now()
+			err := home.Play(ctx, media, options)
+			report[i] = PlayResult{Device: home.Name, Error: err, Duration: time.Since(start)}
+		}(i, home)
+	}
+	wg.Wait()
+
+	return report, nil
+}
+
+type PlayResult struct {
+	Device string
+	Error  error
+	// Duration is how long the device took, from the connection to the end of the message
+	Duration time.Duration
+}
+
+// PlayReport is the results of every device a message was played on.
+type PlayReport []PlayResult
+
+// Err returns an error if some of the devices failed to play the message.
+func (r PlayReport) Err() error {
+	var failed []string
+	for _, result := range r {
+		if result.Error != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", result.Device, result.Error))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to play sound on %s", strings.Join(failed, ", "))
+}
+
+// Succeeded returns the names of the devices which played the message.
+func (r PlayReport) Succeeded() []string {
+	var names []string
+	for _, result := range r {
+		if result.Error == nil {
+			names = append(names, result.Device)
+		}
+	}
+	return names
+}
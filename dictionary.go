@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+// UserDictEngine is implemented by the engines which can use a user dictionary.
+type UserDictEngine interface {
+	// UseUserDict makes the engine read the words in dict.
+	// It is called again whenever dict is changed.
+	UseUserDict(dict *voicevox.UserDict) error
+}
+
+// SurfaceReplacer is implemented by the engines which replace the words with their pronunciations
+// instead of giving the dictionary to the analyzer. The accent type, the word type and the priority
+// of a word do not change how they read it.
+type SurfaceReplacer interface {
+	ReplacesSurfaces() bool
+}
+
+// Dictionary keeps the user dictionary in a file and gives it to the engine.
+//
+//	dict add KMC ケーエムシー [accent type]
+//	dict remove KMC
+//	dict list
+type Dictionary struct {
+	path   string
+	dict   *voicevox.UserDict
+	engine UserDictEngine
+	// pronunciationOnly is set if the engine reads the words only by their pronunciations
+	pronunciationOnly bool
+
+	mu sync.Mutex
+}
+
+// NewDictionary reads the dictionary at path and gives it to engine.
+// If path is empty, the words are not saved.
+func NewDictionary(path string, engine UserDictEngine) (*Dictionary, error) {
+	var d = &Dictionary{
+		path:   path,
+		dict:   voicevox.NewUserDict(),
+		engine: engine,
+	}
+	if replacer, ok := engine.(SurfaceReplacer); ok {
+		d.pronunciationOnly = replacer.ReplacesSurfaces()
+	}
+
+	if path != "" {
+		err := d.dict.Load(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("Load: %v", err)
+		}
+	}
+
+	err := engine.UseUserDict(d.dict)
+	if err != nil {
+		return nil, fmt.Errorf("UseUserDict: %v", err)
+	}
+
+	return d, nil
+}
+
+// Add adds a word, or replaces the pronunciation of the word if surface is already in the dictionary.
+func (d *Dictionary) Add(surface, pronunciation string, accentType int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var word = voicevox.NewUserDictWord(surface, pronunciation, accentType)
+
+	var err error
+	if uuid, old, ok := d.dict.FindWord(surface); ok {
+		word.WordType, word.Priority = old.WordType, old.Priority
+		err = d.dict.UpdateWord(uuid, word)
+	} else {
+		_, err = d.dict.AddWord(word)
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.changed()
+}
+
+// Remove removes the word of surface.
+func (d *Dictionary) Remove(surface string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	uuid, _, ok := d.dict.FindWord(surface)
+	if !ok {
+		return fmt.Errorf("%s is not in the dictionary", surface)
+	}
+
+	err := d.dict.RemoveWord(uuid)
+	if err != nil {
+		return err
+	}
+
+	return d.changed()
+}
+
+// Words returns the words sorted by surface.
+func (d *Dictionary) Words() []voicevox.VoicevoxUserDictWord {
+	var words []voicevox.VoicevoxUserDictWord
+	for _, word := range d.dict.Words() {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		return words[i].Surface < words[j].Surface
+	})
+	return words
+}
+
+// changed saves the dictionary and gives it to the engine again. d.mu must be held.
+func (d *Dictionary) changed() error {
+	if d.path != "" {
+		err := d.dict.Save(d.path)
+		if err != nil {
+			return fmt.Errorf("Save: %v", err)
+		}
+	}
+
+	err := d.engine.UseUserDict(d.dict)
+	if err != nil {
+		return fmt.Errorf("UseUserDict: %v", err)
+	}
+
+	return nil
+}
+
+// Command is the CommandFunc of "dict".
+func (d *Dictionary) Command(args []string) (string, error) {
+	const usage = "Usage: dict add <word> <katakana> [accent type] / dict remove <word> / dict list"
+
+	if len(args) == 0 {
+		return usage, nil
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 && len(args) != 4 {
+			return usage, nil
+		}
+
+		var accentType = 0
+		if len(args) == 4 {
+			var err error
+			accentType, err = strconv.Atoi(args[3])
+			if err != nil {
+				return "", fmt.Errorf("invalid accent type %q", args[3])
+			}
+		}
+
+		err := d.Add(args[1], args[2], accentType)
+		if err != nil {
+			return "", err
+		}
+		if d.pronunciationOnly {
+			return fmt.Sprintf("%s is now read as %s. The engine replaces the word with the pronunciation, so the accent type is ignored.", args[1], args[2]), nil
+		}
+		return fmt.Sprintf("%s is now read as %s.", args[1], args[2]), nil
+
+	case "remove":
+		if len(args) != 2 {
+			return usage, nil
+		}
+
+		err := d.Remove(args[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s was removed from the dictionary.", args[1]), nil
+
+	case "list":
+		var words = d.Words()
+		if len(words) == 0 {
+			return "The dictionary is empty.", nil
+		}
+
+		var lines []string
+		for _, word := range words {
+			if d.pronunciationOnly {
+				lines = append(lines, fmt.Sprintf("%s: %s", word.Surface, word.Pronunciation))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s (%d)", word.Surface, word.Pronunciation, word.AccentType))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+// fakeUserDictEngine counts the dictionaries given to it.
+type fakeUserDictEngine struct {
+	uses             int
+	replacesSurfaces bool
+}
+
+func (e *fakeUserDictEngine) UseUserDict(dict *voicevox.UserDict) error {
+	e.uses++
+	return nil
+}
+
+func (e *fakeUserDictEngine) ReplacesSurfaces() bool {
+	return e.replacesSurfaces
+}
+
+func TestDictionaryCommand(t *testing.T) {
+	var tests = []struct {
+		name             string
+		replacesSurfaces bool
+		added            string
+		listed           string
+	}{
+		{"analyzer", false, "KMC is now read as ケーエムシー.", "KMC: ケーエムシー (1)"},
+		{"replacing surfaces", true, "KMC is now read as ケーエムシー. The engine replaces the word with the pronunciation, so the accent type is ignored.", "KMC: ケーエムシー"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var engine = &fakeUserDictEngine{replacesSurfaces: tt.replacesSurfaces}
+			d, err := NewDictionary("", engine)
+			if err != nil {
+				t.Fatalf("NewDictionary: %v", err)
+			}
+
+			answer, err := d.Command(strings.Fields("add KMC ケーエムシー 1"))
+			if err != nil {
+				t.Fatalf("dict add: %v", err)
+			}
+			if answer != tt.added {
+				t.Errorf("dict add answered %q, want %q", answer, tt.added)
+			}
+
+			answer, _ = d.Command([]string{"list"})
+			if answer != tt.listed {
+				t.Errorf("dict list answered %q, want %q", answer, tt.listed)
+			}
+
+			if engine.uses != 2 {
+				t.Errorf("UseUserDict was called %d times, want 2", engine.uses)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishen/go-chromecast/dns"
+)
+
+const defaultDiscoveryTimeout = 5 * time.Second
+
+// Discoverer browses the network for cast devices.
+// The default implementation uses mDNS, but anything which reports
+// dns.CastEntry values (e.g. a fake responder in tests) can stand in.
+type Discoverer interface {
+	Discover(ctx context.Context) (<-chan dns.CastEntry, error)
+}
+
+type mdnsDiscoverer struct {
+	iface *net.Interface
+}
+
+// NewMDNSDiscoverer returns a Discoverer which browses _googlecast._tcp over mDNS.
+// If iface is nil, every multicast capable interface is used.
+func NewMDNSDiscoverer(iface *net.Interface) Discoverer {
+	return &mdnsDiscoverer{iface: iface}
+}
+
+func newDiscoverer(settings GoogleHomeSetting) (Discoverer, error) {
+	if settings.Iface == "" {
+		return NewMDNSDiscoverer(nil), nil
+	}
+
+	iface, err := net.InterfaceByName(settings.Iface)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find interface %q: %v", settings.Iface, err)
+	}
+
+	return NewMDNSDiscoverer(iface), nil
+}
+
+func (d *mdnsDiscoverer) Discover(ctx context.Context) (<-chan dns.CastEntry, error) {
+	return dns.DiscoverCastDNSEntries(ctx, d.iface)
+}
+
+// DeviceResolver finds the address of the Google Home specified in GoogleHomeSetting.
+// A resolved address is cached until Invalidate is called.
+type DeviceResolver struct {
+	settings   GoogleHomeSetting
+	discoverer Discoverer
+
+	mu     sync.Mutex
+	cached *dns.CastEntry
+}
+
+func NewDeviceResolver(settings GoogleHomeSetting, discoverer Discoverer) *DeviceResolver {
+	return &DeviceResolver{
+		settings:   settings,
+		discoverer: discoverer,
+	}
+}
+
+// Resolve returns the address and the port of the device.
+// When none of DeviceName, Device and UUID are specified, Addr and Port are used as they are.
+func (r *DeviceResolver) Resolve() (string, int, error) {
+	if !r.useDiscovery() {
+		return r.settings.Addr, r.settings.Port, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil {
+		return r.cached.GetAddr(), r.cached.GetPort(), nil
+	}
+
+	entry, err := r.discover()
+	if err != nil {
+		if r.settings.Addr != "" {
+			slog.Warn("Failed to discover the device, so the address in the settings is used", "device", r.settings.Name, "addr", fmt.Sprintf("%s:%d", r.settings.Addr, r.settings.Port), "error", err)
+			return r.settings.Addr, r.settings.Port, nil
+		}
+		return "", 0, err
+	}
+
+	r.cached = &entry
+
+	return entry.GetAddr(), entry.GetPort(), nil
+}
+
+// Invalidate drops the cached address so that the next Resolve browses the network again.
+func (r *DeviceResolver) Invalidate() {
+	r.mu.Lock()
+	r.cached = nil
+	r.mu.Unlock()
+}
+
+func (r *DeviceResolver) useDiscovery() bool {
+	return r.settings.DeviceName != "" || r.settings.Device != "" || r.settings.UUID != ""
+}
+
+func (r *DeviceResolver) discover() (dns.CastEntry, error) {
+	var timeout = defaultDiscoveryTimeout
+	if r.settings.DiscoveryTimeout > 0 {
+		timeout = time.Duration(r.settings.DiscoveryTimeout * float32(time.Second))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	entries, err := r.discoverer.Discover(ctx)
+	if err != nil {
+		return dns.CastEntry{}, fmt.Errorf("Discover: %v", err)
+	}
+
+	// keep the browser from blocking on a channel nobody reads any more
+	defer func() {
+		go func() {
+			for range entries {
+			}
+		}()
+	}()
+
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return dns.CastEntry{}, fmt.Errorf("No cast device matched the settings")
+			}
+			if r.match(entry) {
+				return entry, nil
+			}
+		case <-ctx.Done():
+			return dns.CastEntry{}, fmt.Errorf("No cast device matched the settings within %s", timeout)
+		}
+	}
+}
+
+func (r *DeviceResolver) match(entry dns.CastEntry) bool {
+	if r.settings.UUID != "" && normalizeUUID(r.settings.UUID) != normalizeUUID(entry.UUID) {
+		return false
+	}
+	if r.settings.DeviceName != "" && r.settings.DeviceName != entry.DeviceName {
+		return false
+	}
+	if r.settings.Device != "" && r.settings.Device != entry.Device {
+		return false
+	}
+	return true
+}
+
+// normalizeUUID makes "01234567-89ab-..." and "0123456789AB..." comparable,
+// since the mDNS TXT record omits the hyphens.
+func normalizeUUID(uuid string) string {
+	return strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/vishen/go-chromecast/dns"
+)
+
+// fakeDiscoverer reports entries as an mDNS responder would, and counts the browses.
+type fakeDiscoverer struct {
+	entries []dns.CastEntry
+	err     error
+	calls   int
+}
+
+func (d *fakeDiscoverer) Discover(ctx context.Context) (<-chan dns.CastEntry, error) {
+	d.calls++
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var c = make(chan dns.CastEntry, len(d.entries))
+	for _, entry := range d.entries {
+		c <- entry
+	}
+	close(c)
+	return c, nil
+}
+
+var testCastEntries = []dns.CastEntry{
+	{AddrV4: net.IPv4(192, 168, 0, 10), Port: 8009, DeviceName: "Living Room", Device: "Google Home Mini", UUID: "0123456789abcdef0123456789abcdef"},
+	{AddrV4: net.IPv4(192, 168, 0, 11), Port: 8010, DeviceName: "Kitchen", Device: "Google Nest Hub", UUID: "fedcba9876543210fedcba9876543210"},
+	{AddrV6: net.ParseIP("fe80::1"), Port: 8009, DeviceName: "Bedroom", Device: "Google Home Mini", UUID: "00000000000000000000000000000001"},
+}
+
+func TestDeviceResolverMatch(t *testing.T) {
+	var tests = []struct {
+		name     string
+		settings GoogleHomeSetting
+		addr     string
+		port     int
+	}{
+		{"name", GoogleHomeSetting{DeviceName: "Kitchen"}, "192.168.0.11", 8010},
+		{"model", GoogleHomeSetting{Device: "Google Nest Hub"}, "192.168.0.11", 8010},
+		{"first of the model", GoogleHomeSetting{Device: "Google Home Mini"}, "192.168.0.10", 8009},
+		{"name and model", GoogleHomeSetting{DeviceName: "Bedroom", Device: "Google Home Mini"}, "[fe80::1]", 8009},
+		{"uuid", GoogleHomeSetting{UUID: "fedcba9876543210fedcba9876543210"}, "192.168.0.11", 8010},
+		{"uuid with hyphens and upper case", GoogleHomeSetting{UUID: "01234567-89AB-CDEF-0123-456789ABCDEF"}, "192.168.0.10", 8009},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = NewDeviceResolver(tt.settings, &fakeDiscoverer{entries: testCastEntries})
+
+			addr, port, err := r.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if addr != tt.addr || port != tt.port {
+				t.Errorf("Resolve = %s:%d, want %s:%d", addr, port, tt.addr, tt.port)
+			}
+		})
+	}
+}
+
+func TestDeviceResolverNoMatch(t *testing.T) {
+	var r = NewDeviceResolver(GoogleHomeSetting{DeviceName: "Kitchen", Device: "Google Home Mini"}, &fakeDiscoverer{entries: testCastEntries})
+
+	_, _, err := r.Resolve()
+	if err == nil {
+		t.Fatal("Resolve succeeded without a matching device")
+	}
+}
+
+func TestDeviceResolverCache(t *testing.T) {
+	var discoverer = &fakeDiscoverer{entries: testCastEntries}
+	var r = NewDeviceResolver(GoogleHomeSetting{DeviceName: "Kitchen"}, discoverer)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := r.Resolve()
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+	}
+	if discoverer.calls != 1 {
+		t.Errorf("Discover was called %d times, want 1", discoverer.calls)
+	}
+
+	// the device got a new address while it was cached
+	discoverer.entries = []dns.CastEntry{
+		{AddrV4: net.IPv4(192, 168, 0, 20), Port: 8009, DeviceName: "Kitchen"},
+	}
+
+	addr, _, _ := r.Resolve()
+	if addr != "192.168.0.11" {
+		t.Errorf("Resolve = %s before Invalidate, want the cached 192.168.0.11", addr)
+	}
+
+	r.Invalidate()
+
+	addr, port, err := r.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if addr != "192.168.0.20" || port != 8009 {
+		t.Errorf("Resolve = %s:%d after Invalidate, want 192.168.0.20:8009", addr, port)
+	}
+	if discoverer.calls != 2 {
+		t.Errorf("Discover was called %d times, want 2", discoverer.calls)
+	}
+}
+
+func TestDeviceResolverFallback(t *testing.T) {
+	var tests = []struct {
+		name       string
+		discoverer *fakeDiscoverer
+	}{
+		{"no match", &fakeDiscoverer{entries: testCastEntries}},
+		{"discovery error", &fakeDiscoverer{err: errors.New("no multicast")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settings = GoogleHomeSetting{DeviceName: "Garage", Addr: "192.168.0.99", Port: 8009}
+			var r = NewDeviceResolver(settings, tt.discoverer)
+
+			addr, port, err := r.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if addr != "192.168.0.99" || port != 8009 {
+				t.Errorf("Resolve = %s:%d, want the fallback 192.168.0.99:8009", addr, port)
+			}
+
+			// the fallback is not cached, so the device is looked for again
+			r.Resolve()
+			if tt.discoverer.calls != 2 {
+				t.Errorf("Discover was called %d times, want 2", tt.discoverer.calls)
+			}
+		})
+	}
+}
+
+func TestDeviceResolverWithoutDiscovery(t *testing.T) {
+	var discoverer = &fakeDiscoverer{entries: testCastEntries}
+	var r = NewDeviceResolver(GoogleHomeSetting{Addr: "192.168.0.50", Port: 8009}, discoverer)
+
+	addr, port, err := r.Resolve()
+	if err != nil || addr != "192.168.0.50" || port != 8009 {
+		t.Errorf("Resolve = %s:%d, %v, want 192.168.0.50:8009", addr, port, err)
+	}
+	if discoverer.calls != 0 {
+		t.Errorf("Discover was called %d times without DeviceName, Device and UUID", discoverer.calls)
+	}
+}
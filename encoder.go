@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/audio"
+)
+
+const defaultBitrate = 64
+
+// speechSampleRate is the sample rate of VOICEVOX, which the MP3 bitrate is checked for at startup.
+// A sound of another sample rate which cannot be encoded at the bitrate is sent as WAV.
+const speechSampleRate = 24000
+
+// Encoder converts the synthesized sounds to the format sent to the devices.
+//
+//	wav      WAV (default)
+//	mp3      MP3 by the built-in encoder
+//	command  an external command such as ffmpeg, which reads a WAV from the standard input
+//	         and writes the encoded sound to the standard output. "{bitrate}" in the arguments
+//	         is replaced with the bitrate in kbps.
+//
+// Sounds are encoded as streams, so that the sentences of a message are played without gaps.
+type Encoder struct {
+	settings EncodingSetting
+}
+
+// StreamEncoder encodes the sounds written to it one after another into one stream.
+type StreamEncoder interface {
+	Write(sound *audio.PCM) error
+	// Close writes the rest of the stream. It does not close the underlying writer.
+	Close() error
+}
+
+func NewEncoder(settings EncodingSetting) (*Encoder, error) {
+	if settings.Bitrate == 0 {
+		settings.Bitrate = defaultBitrate
+	}
+
+	switch settings.Format {
+	case "", "wav":
+		settings.Format = "wav"
+		settings.MIMEType = "audio/wav"
+	case "mp3":
+		err := audio.CheckMP3Bitrate(settings.Bitrate, speechSampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("Encoding.Bitrate: %v", err)
+		}
+		if settings.MIMEType == "" {
+			settings.MIMEType = "audio/mpeg"
+		}
+	case "command":
+		if len(settings.Command) == 0 {
+			return nil, fmt.Errorf("Encoding.Command is empty")
+		}
+		if settings.MIMEType == "" {
+			return nil, fmt.Errorf("Encoding.MIMEType is required for the command format")
+		}
+		_, err := exec.LookPath(settings.Command[0])
+		if err != nil {
+			return nil, fmt.Errorf("LookPath: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown encoding format %q", settings.Format)
+	}
+
+	return &Encoder{settings: settings}, nil
+}
+
+// NewStream returns an encoder writing to w and the MIME type of the stream.
+// If the encoder of the format cannot be started, a WAV stream is returned with the error,
+// so that the speech can still be played.
+func (e *Encoder) NewStream(w io.Writer, sampleRate, channels int) (StreamEncoder, string, error) {
+	var err error
+
+	// the encoders are kept in their own types until they are known to be started,
+	// since a nil pointer in a StreamEncoder is not a nil StreamEncoder
+	switch e.settings.Format {
+	case "mp3":
+		var stream *audio.MP3Encoder
+		stream, err = audio.NewMP3Encoder(w, sampleRate, channels, e.settings.Bitrate)
+		if err == nil {
+			return stream, e.settings.MIMEType, nil
+		}
+	case "command":
+		var stream *commandStream
+		stream, err = e.newCommandStream(w, sampleRate, channels)
+		if err == nil {
+			return stream, e.settings.MIMEType, nil
+		}
+	}
+
+	wav, werr := newWAVStream(w, sampleRate, channels)
+	if werr != nil {
+		return nil, "", werr
+	}
+	return wav, "audio/wav", err
+}
+
+// wavStream writes a WAV whose length is not known.
+type wavStream struct {
+	w          io.Writer
+	sampleRate int
+	channels   int
+}
+
+func newWAVStream(w io.Writer, sampleRate, channels int) (*wavStream, error) {
+	_, err := w.Write(audio.StreamingWAVHeader(sampleRate, channels))
+	if err != nil {
+		return nil, fmt.Errorf("Write: %v", err)
+	}
+	return &wavStream{w: w, sampleRate: sampleRate, channels: channels}, nil
+}
+
+func (s *wavStream) Write(sound *audio.PCM) error {
+	_, err := s.w.Write(sound.Convert(s.sampleRate, s.channels).Bytes())
+	return err
+}
+
+func (s *wavStream) Close() error {
+	return nil
+}
+
+// commandStream pipes a WAV stream into the command and its output to w.
+type commandStream struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	wav    *wavStream
+	stderr bytes.Buffer
+
+	wg      sync.WaitGroup
+	copyErr error
+}
+
+func (e *Encoder) newCommandStream(w io.Writer, sampleRate, channels int) (*commandStream, error) {
+	var replacer = strings.NewReplacer("{bitrate}", strconv.Itoa(e.settings.Bitrate))
+
+	var args []string
+	for _, arg := range e.settings.Command[1:] {
+		args = append(args, replacer.Replace(arg))
+	}
+
+	var s = &commandStream{name: e.settings.Command[0]}
+	s.cmd = exec.Command(e.settings.Command[0], args...)
+	s.cmd.Stderr = &s.stderr
+
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("StdinPipe: %v", err)
+	}
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("StdoutPipe: %v", err)
+	}
+
+	err = s.cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.name, err)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_, s.copyErr = io.Copy(w, stdout)
+	}()
+
+	s.stdin = stdin
+	s.wav, err = newWAVStream(stdin, sampleRate, channels)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *commandStream) Write(sound *audio.PCM) error {
+	err := s.wav.Write(sound)
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", s.name, err, strings.TrimSpace(s.stderr.String()))
+	}
+	return nil
+}
+
+func (s *commandStream) Close() error {
+	s.stdin.Close()
+	s.wg.Wait()
+
+	err := s.cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", s.name, err, strings.TrimSpace(s.stderr.String()))
+	}
+	if s.copyErr != nil {
+		return fmt.Errorf("%s: %v", s.name, s.copyErr)
+	}
+	return nil
+}
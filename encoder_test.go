@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/audio"
+)
+
+func TestEncoderNewStream(t *testing.T) {
+	var tests = []struct {
+		name       string
+		settings   EncodingSetting
+		sampleRate int
+		mimeType   string
+		fails      bool
+	}{
+		{"wav", EncodingSetting{Format: "wav", MIMEType: "audio/wav"}, 24000, "audio/wav", false},
+		{"mp3", EncodingSetting{Format: "mp3", Bitrate: 64, MIMEType: "audio/mpeg"}, 24000, "audio/mpeg", false},
+		// 24 kHz is MPEG 2, which has no 192 kbps
+		{"mp3 bitrate of another sample rate", EncodingSetting{Format: "mp3", Bitrate: 192, MIMEType: "audio/mpeg"}, 24000, "audio/wav", true},
+		{"command which cannot start", EncodingSetting{Format: "command", Command: []string{"/nonexistent/encoder"}, MIMEType: "audio/ogg"}, 24000, "audio/wav", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e = &Encoder{settings: tt.settings}
+			var buf bytes.Buffer
+
+			stream, mimeType, err := e.NewStream(&buf, tt.sampleRate, 1)
+			if stream == nil {
+				t.Fatalf("NewStream returned no stream: %v", err)
+			}
+			if (err != nil) != tt.fails {
+				t.Errorf("NewStream error = %v, want an error %v", err, tt.fails)
+			}
+			if mimeType != tt.mimeType {
+				t.Errorf("MIME type = %q, want %q", mimeType, tt.mimeType)
+			}
+
+			// the stream must be usable even when the encoder failed to start
+			err = stream.Write(audio.Silence(tt.sampleRate, 1, 100*time.Millisecond))
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			err = stream.Close()
+			if err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if tt.mimeType == "audio/wav" && !bytes.HasPrefix(buf.Bytes(), []byte("RIFF")) {
+				t.Errorf("the stream does not start with a WAV header: % x", buf.Bytes()[:min(buf.Len(), 12)])
+			}
+		})
+	}
+}
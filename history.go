@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHistoryEntries is the number of entries kept when History.MaxEntries is not set.
+	defaultHistoryEntries = 1000
+	// minHistoryCompaction is the number of old lines the file may have before it is rewritten
+	minHistoryCompaction = 100
+	// defaultHistoryList and maxHistoryList are the entries shown by the history command.
+	defaultHistoryList = 10
+	maxHistoryList     = 100
+	// maxHistoryListText is the number of characters of a text shown by the history command.
+	maxHistoryListText = 50
+)
+
+// HistoryEntry is a message which was spoken or given up.
+type HistoryEntry struct {
+	ID string `json:"id"`
+	// Time is when the message finished
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	User    string    `json:"user,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Text    string    `json:"text"`
+	// NormalizedText is what was synthesized. It is empty if the message was not synthesized.
+	NormalizedText string `json:"normalized_text,omitempty"`
+	Speaker        string `json:"speaker"`
+	// Options are the options the message was spoken with, which a replay uses again
+	Options MessageOptions    `json:"options"`
+	Devices []JobDeviceResult `json:"devices,omitempty"`
+	// Duration is the length of the sound in seconds
+	Duration float64 `json:"duration"`
+	Result   string  `json:"result"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// History keeps the recent messages, and saves them to a JSON Lines file if HistorySetting.File is set.
+// An entry is appended to the file when it is added,
+// and the file is rewritten once it has as many old lines as entries.
+type History struct {
+	settings HistorySetting
+
+	mu sync.Mutex
+	// entries are in the order they were added
+	entries []HistoryEntry
+	// lines is the number of entries in the file, which includes the ones removed from entries
+	lines int
+}
+
+func NewHistory(settings HistorySetting) (*History, error) {
+	var h = &History{settings: settings}
+
+	if settings.File == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(settings.File)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Open: %v", err)
+	}
+	defer f.Close()
+
+	var scanner = bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		h.lines++
+
+		var entry HistoryEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			slog.Warn("Skipped a broken line of the history", "file", settings.File, "line", h.lines, "error", err)
+			continue
+		}
+		h.entries = append(h.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Read: %s: %v", settings.File, err)
+	}
+
+	h.prune(time.Now())
+	if h.lines > len(h.entries) {
+		h.rewrite()
+	}
+
+	return h, nil
+}
+
+func (h *History) maxEntries() int {
+	if h.settings.MaxEntries <= 0 {
+		return defaultHistoryEntries
+	}
+	return h.settings.MaxEntries
+}
+
+// prune removes the entries over MaxEntries and the ones older than MaxDays. h.mu must be held.
+func (h *History) prune(now time.Time) {
+	var drop = max(len(h.entries)-h.maxEntries(), 0)
+
+	if h.settings.MaxDays > 0 {
+		var oldest = now.Add(-time.Duration(h.settings.MaxDays * float32(24*time.Hour)))
+		for drop < len(h.entries) && h.entries[drop].Time.Before(oldest) {
+			drop++
+		}
+	}
+
+	h.entries = h.entries[drop:]
+}
+
+// Add records entry.
+func (h *History) Add(entry HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, entry)
+	h.prune(time.Now())
+
+	if h.settings.File == "" {
+		return
+	}
+
+	if h.lines-len(h.entries) >= max(len(h.entries), minHistoryCompaction) {
+		h.rewrite()
+		return
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to marshal the history", "error", err)
+		return
+	}
+
+	f, err := os.OpenFile(h.settings.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		slog.Error("Failed to save the history", "error", err)
+		return
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		slog.Error("Failed to save the history", "error", err)
+		return
+	}
+	h.lines++
+}
+
+// rewrite writes only the entries to the file. h.mu must be held.
+func (h *History) rewrite() {
+	var b []byte
+	for _, entry := range h.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			slog.Error("Failed to marshal the history", "error", err)
+			return
+		}
+		b = append(append(b, line...), '\n')
+	}
+
+	// write to a temporary file and rename it, so that the file is never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(h.settings.File), filepath.Base(h.settings.File)+".*")
+	if err != nil {
+		slog.Error("Failed to save the history", "error", err)
+		return
+	}
+	_, err = tmp.Write(b)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Error("Failed to save the history", "error", err)
+		return
+	}
+
+	err = os.Rename(tmp.Name(), h.settings.File)
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Error("Failed to save the history", "error", err)
+		return
+	}
+	h.lines = len(h.entries)
+}
+
+// Recent returns the last n entries, the newest first.
+func (h *History) Recent(n int) []HistoryEntry {
+	return h.recent(n, func(HistoryEntry) bool { return true })
+}
+
+// RecentIn returns the last n entries of the messages sent from channel, the newest first.
+func (h *History) RecentIn(channel string, n int) []HistoryEntry {
+	return h.recent(n, func(entry HistoryEntry) bool { return entry.Channel == channel })
+}
+
+func (h *History) recent(n int, match func(HistoryEntry) bool) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var entries []HistoryEntry
+	for i := len(h.entries) - 1; i >= 0 && len(entries) < n; i-- {
+		if match(h.entries[i]) {
+			entries = append(entries, h.entries[i])
+		}
+	}
+	return entries
+}
+
+// Get returns the entry of id.
+func (h *History) Get(id string) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].ID == id {
+			return h.entries[i], true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Command is the CommandFunc of "history", which lists the last messages.
+//
+//	history       lists the last 10 messages
+//	history 30    lists the last 30 messages
+func (h *History) Command(args []string) (string, error) {
+	return listHistory(args, h.Recent)
+}
+
+// ChannelCommand returns the CommandFunc of "history" for channel,
+// which lists only the messages sent from channel.
+func (h *History) ChannelCommand(channel string) CommandFunc {
+	return func(args []string) (string, error) {
+		return listHistory(args, func(n int) []HistoryEntry {
+			return h.RecentIn(channel, n)
+		})
+	}
+}
+
+// listHistory answers the history command with the entries returned by recent.
+func listHistory(args []string, recent func(n int) []HistoryEntry) (string, error) {
+	var n = defaultHistoryList
+	if len(args) > 0 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("the number of messages must be a positive integer")
+		}
+		n = min(n, maxHistoryList)
+	}
+
+	var entries = recent(n)
+	if len(entries) == 0 {
+		return "No message has been spoken yet.", nil
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// String returns a line of the history command.
+func (e HistoryEntry) String() string {
+	var text = []rune(e.Text)
+	if len(text) > maxHistoryListText {
+		text = append(text[:maxHistoryListText], '…')
+	}
+
+	var devices []string
+	for _, device := range e.Devices {
+		devices = append(devices, device.Device)
+	}
+	var played = "-"
+	if len(devices) > 0 {
+		played = strings.Join(devices, ",")
+	}
+
+	return fmt.Sprintf("[%s] %s %s → %s (%s, %.1fs) %s",
+		e.ID, e.Time.Local().Format("01/02 15:04"), e.Source, played, e.Result, e.Duration, string(text))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryChannelCommand(t *testing.T) {
+	history, err := NewHistory(HistorySetting{})
+	if err != nil {
+		t.Fatalf("NewHistory: %v", err)
+	}
+
+	var now = time.Now()
+	history.Add(HistoryEntry{ID: "1", Time: now, Source: "slack", Channel: "CPUBLIC", Text: "公開"})
+	history.Add(HistoryEntry{ID: "2", Time: now, Source: "slack", Channel: "GPRIVATE", Text: "秘密"})
+	history.Add(HistoryEntry{ID: "3", Time: now, Source: "http", Text: "API"})
+	history.Add(HistoryEntry{ID: "4", Time: now, Source: "slack", Channel: "CPUBLIC", Text: "二つ目"})
+
+	var ids = func(entries []HistoryEntry) string {
+		var ids []string
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := ids(history.Recent(10)); got != "4,3,2,1" {
+		t.Errorf("Recent = %s, want 4,3,2,1", got)
+	}
+	if got := ids(history.RecentIn("CPUBLIC", 10)); got != "4,1" {
+		t.Errorf("RecentIn(CPUBLIC) = %s, want 4,1", got)
+	}
+	if got := ids(history.RecentIn("CPUBLIC", 1)); got != "4" {
+		t.Errorf("RecentIn(CPUBLIC, 1) = %s, want 4", got)
+	}
+
+	answer, err := history.ChannelCommand("CPUBLIC")(nil)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if strings.Contains(answer, "秘密") || strings.Contains(answer, "API") || !strings.Contains(answer, "公開") {
+		t.Errorf("history in CPUBLIC answered %q", answer)
+	}
+
+	answer, _ = history.ChannelCommand("CEMPTY")([]string{"5"})
+	if answer != "No message has been spoken yet." {
+		t.Errorf("history in CEMPTY answered %q", answer)
+	}
+
+	_, err = history.ChannelCommand("CPUBLIC")([]string{"0"})
+	if err == nil {
+		t.Error("history 0 succeeded")
+	}
+}
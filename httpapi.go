@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/metrics"
+)
+
+type speakRequest struct {
+	Text      string   `json:"text"`
+	SpeakerID *uint32  `json:"speaker_id"`
+	Voice     string   `json:"voice"`
+	Volume    *float32 `json:"volume"`
+	Target    string   `json:"target"`
+	Priority  Priority `json:"priority"`
+	// speed_scale, pitch_scale, ... are given at the top level
+	Prosody
+}
+
+type speakResponse struct {
+	JobID string `json:"job_id"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// HTTPSource serves the HTTP API.
+//
+//	POST /speak                queues a text and returns its job ID
+//	GET  /jobs/{id}            returns the status of the job
+//	GET  /status               returns the status of every device
+//	GET  /metrics              returns the metrics in the Prometheus text format
+//	GET  /history              returns the last messages, the newest first (?limit=10, up to 100, ?channel= for a Slack channel)
+//	GET  /history/{id}         returns the message in the history
+//	POST /history/{id}/replay  queues the message again and returns its job ID
+type HTTPSource struct {
+	settings HTTPSetting
+	jobs     *JobTracker
+	devices  *GoogleHomes
+	history  *History
+}
+
+func NewHTTPSource(settings HTTPSetting, jobs *JobTracker, devices *GoogleHomes, history *History) *HTTPSource {
+	return &HTTPSource{
+		settings: settings,
+		jobs:     jobs,
+		devices:  devices,
+		history:  history,
+	}
+}
+
+func (s *HTTPSource) Name() string {
+	return "http"
+}
+
+// Start starts the HTTP server.
+// The results are not pushed anywhere, since clients poll /jobs/{id}.
+func (s *HTTPSource) Start(ctx context.Context, submit func(*Message) error) error {
+	if s.settings.Token == "" {
+		return fmt.Errorf("HTTP.Token is empty")
+	}
+	if s.settings.Listen == "" {
+		return fmt.Errorf("HTTP.Listen is empty")
+	}
+
+	var mux = http.NewServeMux()
+
+	mux.HandleFunc("/speak", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		var req speakRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "text is empty"})
+			return
+		}
+
+		if req.Volume != nil && (*req.Volume < 0 || *req.Volume > 1) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "volume must be between 0 and 1"})
+			return
+		}
+
+		err = req.Prosody.Validate()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		var options = MessageOptions{
+			Voice:    req.Voice,
+			Priority: req.Priority,
+			Target:   req.Target,
+			Volume:   req.Volume,
+			Prosody:  req.Prosody,
+		}
+		if req.SpeakerID != nil {
+			options.Voice = strconv.FormatUint(uint64(*req.SpeakerID), 10)
+		}
+
+		s.submit(w, r, submit, req.Text, options)
+	})
+
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		job, ok := s.jobs.Get(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "job not found"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s.devices.Status())
+	})
+
+	mux.Handle("/metrics", metrics.Default)
+
+	mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		var limit = defaultHistoryList
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "limit must be a positive integer"})
+				return
+			}
+			limit = min(limit, maxHistoryList)
+		}
+
+		var entries []HistoryEntry
+		if channel := r.URL.Query().Get("channel"); channel != "" {
+			entries = s.history.RecentIn(channel, limit)
+		} else {
+			entries = s.history.Recent(limit)
+		}
+		if entries == nil {
+			entries = []HistoryEntry{}
+		}
+		writeJSON(w, http.StatusOK, entries)
+	})
+
+	mux.HandleFunc("/history/", func(w http.ResponseWriter, r *http.Request) {
+		var path = strings.TrimPrefix(r.URL.Path, "/history/")
+		id, replay := strings.CutSuffix(path, "/replay")
+
+		var method = http.MethodGet
+		if replay {
+			method = http.MethodPost
+		}
+		if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		entry, ok := s.history.Get(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "message not found"})
+			return
+		}
+
+		if !replay {
+			writeJSON(w, http.StatusOK, entry)
+			return
+		}
+		s.submit(w, r, submit, entry.Text, entry.Options)
+	})
+
+	var server = &http.Server{
+		Addr:    s.settings.Listen,
+		Handler: bearerAuth(s.settings.Token, mux),
+	}
+
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("HTTP server stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Start HTTP server", "addr", s.settings.Listen)
+
+	return nil
+}
+
+// submit queues text and writes its job ID.
+func (s *HTTPSource) submit(w http.ResponseWriter, r *http.Request, submit func(*Message) error, text string, options MessageOptions) {
+	var message = NewMessage(s.Name(), text, options)
+	message.Meta["remote"] = r.RemoteAddr
+
+	err := submit(message)
+	if errors.Is(err, ErrQueueFull) {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, speakResponse{JobID: message.ID})
+}
+
+func bearerAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+)
+
+// ReplyFunc reports the result of a message to where the message came from.
+// report is nil if the message did not reach the devices.
+type ReplyFunc func(m *Message, report PlayReport, err error)
+
+// InputSource is a source of messages such as Slack.
+type InputSource interface {
+	// Name is set to Message.Origin of the messages from the source.
+	Name() string
+	// Start starts taking messages and passes them to submit until ctx is done.
+	// If submit returns an error, the message was not queued and the source should report it by itself.
+	Start(ctx context.Context, submit func(*Message) error) error
+}
+
+// ReplyRestorer is implemented by the sources which can still reply to
+// a message restored from the queue file after a restart.
+type ReplyRestorer interface {
+	RestoreReply(m *Message)
+}
+
+// Notifier is implemented by the sources which can tell the sender
+// what happens to a message before its result, such as that it is held.
+type Notifier interface {
+	Notify(m *Message, text string)
+}
+
+// NewInputSources returns the sources listed in settings.Inputs.
+// When Inputs is empty, Slack and the HTTP API are used if they are configured.
+// Slack and stdin answer the commands, and the HTTP API serves the status of devices.
+// Slack controls the messages with its buttons as well.
+// Slack and the HTTP API replay the messages in history.
+func NewInputSources(settings *Setting, jobs *JobTracker, commands Commands, devices *GoogleHomes, controller *Controller, history *History) ([]InputSource, error) {
+	var names = settings.Inputs
+	if len(names) == 0 {
+		if settings.Slack.Token != "" {
+			names = append(names, "slack")
+		}
+		if settings.HTTP.Listen != "" {
+			names = append(names, "http")
+		}
+	}
+
+	var sources []InputSource
+	for _, name := range names {
+		switch name {
+		case "slack":
+			slack, err := NewSlackSource(settings.Slack, settings.Schedule.TimeZone, commands, controller, history)
+			if err != nil {
+				return nil, fmt.Errorf("Slack: %v", err)
+			}
+			sources = append(sources, slack)
+		case "http":
+			sources = append(sources, NewHTTPSource(settings.HTTP, jobs, devices, history))
+		case "stdin":
+			sources = append(sources, NewStdinSource(commands))
+		default:
+			return nil, fmt.Errorf("Unknown input %q", name)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("No input is configured")
+	}
+
+	return sources, nil
+}
+
+// StdinSource takes a message per line from the standard input.
+// Options can be put in front of the text as in Slack.
+type StdinSource struct {
+	commands Commands
+}
+
+func NewStdinSource(commands Commands) *StdinSource {
+	return &StdinSource{commands: commands}
+}
+
+func (s *StdinSource) Name() string {
+	return "stdin"
+}
+
+func (s *StdinSource) Start(ctx context.Context, submit func(*Message) error) error {
+	go func() {
+		var scanner = bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if ctx.Err() != nil {
+				return
+			}
+
+			answer, ok, err := s.commands.Run(scanner.Text())
+			if ok {
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+				} else {
+					fmt.Println(answer)
+				}
+				continue
+			}
+
+			options, text := ParseMessageOptions(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			var message = NewMessage(s.Name(), text, options)
+			message.Reply = s.reply
+
+			err = submit(message)
+			if err != nil {
+				s.reply(message, nil, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *StdinSource) RestoreReply(m *Message) {
+	m.Reply = s.reply
+}
+
+func (s *StdinSource) Notify(m *Message, text string) {
+	fmt.Printf("[%s] %s\n", m.ID, text)
+}
+
+func (s *StdinSource) reply(m *Message, report PlayReport, err error) {
+	if err == nil {
+		err = report.Err()
+	}
+	if err != nil {
+		fmt.Printf("[%s] Error: %v\n", m.ID, err)
+		return
+	}
+	fmt.Printf("[%s] Done: %s\n", m.ID, m.Text)
+}
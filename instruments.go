@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/cache"
+	"github.com/kmc-jp/GoogleHomeNotifier/metrics"
+)
+
+// The metrics served on /metrics of the HTTP API.
+var (
+	synthesisSeconds = metrics.Default.NewHistogram(
+		"googlehome_synthesis_seconds",
+		"Time to synthesize a sentence. Sentences found in the cache are not counted.",
+		[]float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	)
+	playbackSeconds = metrics.Default.NewHistogram(
+		"googlehome_playback_seconds",
+		"Time a device took to play a message, from the connection to the end.",
+		[]float64{1, 2, 5, 10, 20, 30, 60, 120, 300},
+		"device",
+	)
+	deviceErrors = metrics.Default.NewCounter(
+		"googlehome_device_errors_total",
+		"Errors of the devices. kind is play for a message which failed, and connection for a connection which failed or was lost.",
+		"device", "kind",
+	)
+	messagesTotal = metrics.Default.NewCounter(
+		"googlehome_messages_total",
+		"Messages finished, by where they came from and how they ended.",
+		"source", "result",
+	)
+)
+
+// registerMetrics registers the metrics read from queue and audioCache when they are served.
+func registerMetrics(queue *MessageQueue, audioCache *cache.Cache) {
+	metrics.Default.NewGaugeFunc("googlehome_queue_depth", "Messages waiting in the queue.", func() float64 {
+		return float64(queue.Len())
+	})
+	metrics.Default.NewGaugeFunc("googlehome_cache_hit_ratio", "Ratio of the sentences found in the sound cache.", func() float64 {
+		return audioCache.Stats().HitRatio()
+	})
+	metrics.Default.NewCounterFunc("googlehome_cache_hits_total", "Sentences found in the sound cache.", func() float64 {
+		var stats = audioCache.Stats()
+		return float64(stats.MemoryHits + stats.DiskHits)
+	})
+	metrics.Default.NewCounterFunc("googlehome_cache_misses_total", "Sentences not found in the sound cache.", func() float64 {
+		return float64(audioCache.Stats().Misses)
+	})
+}
+
+// messageResult returns the result label of a finished message.
+func messageResult(report PlayReport, err error) string {
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		return "rejected"
+	case errors.Is(err, ErrDropped):
+		return "dropped"
+	case errors.Is(err, ErrCanceled):
+		return "canceled"
+	case errors.Is(err, ErrStopped):
+		return "stopped"
+	case errors.Is(err, ErrShutdown):
+		return "shutdown"
+	case err != nil || report.Err() != nil:
+		return "failed"
+	}
+	return "done"
+}
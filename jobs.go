@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued       JobStatus = "queued"
+	JobHeld         JobStatus = "held"
+	JobSynthesizing JobStatus = "synthesizing"
+	JobPlaying      JobStatus = "playing"
+	JobDone         JobStatus = "done"
+	JobFailed       JobStatus = "failed"
+)
+
+// maxJobs is the number of jobs JobTracker remembers.
+const maxJobs = 1000
+
+type Job struct {
+	ID        string            `json:"id"`
+	Status    JobStatus         `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Devices   []JobDeviceResult `json:"devices,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type JobDeviceResult struct {
+	Device string `json:"device"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobTracker keeps the status of recent messages.
+type JobTracker struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+}
+
+func NewJobTracker() *JobTracker {
+	return &JobTracker{
+		jobs: map[string]*Job{},
+	}
+}
+
+func (t *JobTracker) Set(id string, status JobStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job(id).Status = status
+}
+
+// Finish records the result of the message.
+func (t *JobTracker) Finish(id string, report PlayReport, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var job = t.job(id)
+
+	if err == nil {
+		err = report.Err()
+	}
+
+	job.Status = JobDone
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+
+	job.Devices = deviceResults(report)
+}
+
+// deviceResults returns the results of report with the errors as strings.
+func deviceResults(report PlayReport) []JobDeviceResult {
+	var results []JobDeviceResult
+	for _, result := range report {
+		var r = JobDeviceResult{Device: result.Device}
+		if result.Error != nil {
+			r.Error = result.Error.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (t *JobTracker) Get(id string) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// job returns the job of id, creating it if needed. t.mu must be held.
+func (t *JobTracker) job(id string) *Job {
+	job, ok := t.jobs[id]
+	if !ok {
+		job = &Job{ID: id}
+		t.jobs[id] = job
+		t.order = append(t.order, id)
+
+		if len(t.order) > maxJobs {
+			delete(t.jobs, t.order[0])
+			t.order = t.order[1:]
+		}
+	}
+	job.UpdatedAt = time.Now()
+	return job
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// NewLogger returns the logger of settings, which writes to the standard error.
+// The level is debug if GOOGLE_HOME_DEBUG is on.
+func NewLogger(settings LogSetting) (*slog.Logger, error) {
+	var level slog.Level
+	if settings.Level != "" {
+		err := level.UnmarshalText([]byte(settings.Level))
+		if err != nil {
+			return nil, fmt.Errorf("Level: %v", err)
+		}
+	}
+	if DEBUG {
+		level = slog.LevelDebug
+	}
+
+	var options = &slog.HandlerOptions{
+		Level: level,
+		// durations such as "1.5s" are easier to read than nanoseconds in JSON
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Value.Kind() == slog.KindDuration {
+				return slog.String(a.Key, a.Value.Duration().String())
+			}
+			return a
+		},
+	}
+	switch settings.Format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	}
+	return nil, fmt.Errorf("Unknown format %q", settings.Format)
+}
+
+// messageLogger returns the logger of the lines about m, which carry its ID, its source and its Slack channel.
+func messageLogger(m *Message) *slog.Logger {
+	var logger = slog.With("request_id", m.ID, "source", m.Origin)
+	if channel := m.Meta["channel"]; channel != "" {
+		logger = logger.With("channel", channel)
+	}
+	return logger
+}
+
+// speakerName returns voice for the logs, where the default speaker is "default".
+func speakerName(voice string) string {
+	if voice == "" {
+		return "default"
+	}
+	return voice
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/cache"
+	"github.com/kmc-jp/GoogleHomeNotifier/schedule"
 )
 
 var DEBUG = os.Getenv("GOOGLE_HOME_DEBUG") == "on"
@@ -11,46 +19,349 @@ var DEBUG = os.Getenv("GOOGLE_HOME_DEBUG") == "on"
 func main() {
 	settings, err := ReadSettings()
 	if err != nil {
-		fmt.Println("Failed to read settings.", err)
+		slog.Error("Failed to read settings", "error", err)
 		return
 	}
 
-	ttsinput, ttsoutput, err := StartTTS(settings.Voicevox)
+	rootLogger, err := NewLogger(settings.Log)
 	if err != nil {
-		fmt.Println("Failed to StartTTS.", err)
+		slog.Error("Failed to prepare the logger", "error", err)
 		return
 	}
+	slog.SetDefault(rootLogger)
 
-	slacktextchan, slackdonechan := StartSlack(settings.Slack)
+	engine, err := NewTTSEngine(settings)
+	if err != nil {
+		slog.Error("Failed to prepare the TTS engine", "error", err)
+		return
+	}
+	defer engine.Close()
 
-	fmt.Println("Start waiting messages...")
+	normalizer, err := settings.Normalize.Pipeline()
+	if err != nil {
+		slog.Error("Failed to prepare the text normalization", "error", err)
+		return
+	}
 
-	var sound TtsOutputAttr
+	policy, err := settings.Schedule.Policy()
+	if err != nil {
+		slog.Error("Failed to prepare the schedule", "error", err)
+		return
+	}
 
-	for text := range slacktextchan {
-		text = strings.TrimSpace(text)
-		if text == "" {
-			continue
+	chimes, err := NewChimes(settings.Chime)
+	if err != nil {
+		slog.Error("Failed to read the chimes", "error", err)
+		return
+	}
+
+	encoder, err := NewEncoder(settings.Encoding)
+	if err != nil {
+		slog.Error("Failed to prepare the encoder", "error", err)
+		return
+	}
+
+	mediaServer := NewMediaServer(settings.MediaServer)
+	err = mediaServer.Start(context.Background())
+	if err != nil {
+		slog.Error("Failed to start the media server", "error", err)
+		return
+	}
+
+	audioCache, err := cache.New(settings.Cache.Options())
+	if err != nil {
+		slog.Error("Failed to prepare the sound cache", "error", err)
+		return
+	}
+
+	narrator := NewNarrator(StartTTS(engine, audioCache, settings.TTSWorkers()), chimes, encoder, mediaServer)
+
+	googlehomes, err := NewGoogleHomes(settings)
+	if err != nil {
+		slog.Error("Failed to prepare Google Homes", "error", err)
+		return
+	}
+	googlehomes.Start(context.Background())
+
+	queue, err := NewMessageQueue(settings.Queue)
+	if err != nil {
+		slog.Error("Failed to prepare the message queue", "error", err)
+		return
+	}
+
+	jobs := NewJobTracker()
+
+	history, err := NewHistory(settings.History)
+	if err != nil {
+		slog.Error("Failed to prepare the history", "error", err)
+		return
+	}
+
+	registerMetrics(queue, audioCache)
+
+	// finishSpoken records the result of message and reports it to where the message came from.
+	// text and options are what the message was synthesized with, and duration is the length of the sound.
+	finishSpoken := func(message *Message, text string, options MessageOptions, duration time.Duration, report PlayReport, err error) {
+		jobs.Finish(message.ID, report, err)
+
+		var logger = messageLogger(message)
+		for _, result := range report {
+			switch {
+			case result.Error == nil:
+				playbackSeconds.Observe(result.Duration.Seconds(), result.Device)
+				logger.Info("Played the message", "device", result.Device, "duration", result.Duration)
+			case errors.Is(result.Error, ErrStopped):
+				logger.Info("Stopped the message", "device", result.Device, "duration", result.Duration)
+			default:
+				deviceErrors.Inc(result.Device, "play")
+				logger.Warn("Failed to play the message", "device", result.Device, "error", result.Error)
+			}
 		}
 
-		if sound.FilePath != "" {
-			os.Remove(sound.FilePath)
+		var result = messageResult(report, err)
+		messagesTotal.Inc(message.Origin, result)
+		switch {
+		case err != nil:
+			logger.Warn("Finished the message", "result", result, "error", err)
+		case report.Err() != nil:
+			logger.Warn("Finished the message", "result", result, "error", report.Err())
+		default:
+			logger.Info("Finished the message", "result", result)
 		}
 
-		ttsinput <- text
-		sound = <-ttsoutput
+		var entry = HistoryEntry{
+			ID:             message.ID,
+			Time:           time.Now(),
+			Source:         message.Origin,
+			User:           message.Meta["user"],
+			Channel:        message.Meta["channel"],
+			Text:           message.Text,
+			NormalizedText: text,
+			Speaker:        speakerName(options.Voice),
+			Options:        options,
+			Devices:        deviceResults(report),
+			Duration:       duration.Seconds(),
+			Result:         result,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else if report.Err() != nil {
+			entry.Error = report.Err().Error()
+		}
+		history.Add(entry)
 
-		if sound.Error != nil {
-			slackdonechan <- fmt.Errorf("Failed to synthesize sound: %s", sound.Error)
-			continue
+		if message.Reply != nil {
+			message.Reply(message, report, err)
 		}
+	}
+
+	// finish records the result of message which was not synthesized
+	finish := func(message *Message, report PlayReport, err error) {
+		finishSpoken(message, "", message.Options, 0, report, err)
+	}
+
+	controller := NewController(queue, finish)
+
+	// prefetch synthesizes the next message while the current one is played.
+	// The messages are still played in the order of the queue.
+	prefetch := func() {
+		if next := queue.Peek(); next != nil {
+			narrator.Prefetch(next.ID, normalizer.Apply(next.Text), controller.Options(next.Options))
+		}
+	}
 
-		err = Play(sound, settings.GoogleHome)
+	submit := func(message *Message) error {
+		jobs.Set(message.ID, JobQueued)
+
+		dropped, err := queue.Push(message)
+		if err != nil {
+			jobs.Finish(message.ID, nil, err)
+			messagesTotal.Inc(message.Origin, messageResult(nil, err))
+			messageLogger(message).Warn("Rejected the message", "error", err)
+			return err
+		}
+		messageLogger(message).Info("Queued the message", "priority", message.Options.Priority.String(), "target", message.Options.Target, "queue_depth", queue.Len())
+		if dropped != nil {
+			finish(dropped, nil, ErrDropped)
+		}
+		prefetch()
+		return nil
+	}
+
+	commands := Commands{
+		"status":  googlehomes.StatusCommand,
+		"stop":    controller.StopCommand,
+		"volume":  controller.VolumeCommand,
+		"voice":   controller.VoiceCommand,
+		"queue":   controller.QueueCommand,
+		"cache":   CacheCommand(audioCache),
+		"history": history.Command,
+	}
+
+	if user, ok := engine.(UserDictEngine); ok {
+		dictionary, err := NewDictionary(settings.Voicevox.UserDictFile, user)
+		if err != nil {
+			slog.Error("Failed to prepare the user dictionary", "error", err)
+			return
+		}
+		commands["dict"] = dictionary.Command
+	}
+
+	sources, err := NewInputSources(settings, jobs, commands, googlehomes, controller, history)
+	if err != nil {
+		slog.Error("Failed to prepare inputs", "error", err)
+		return
+	}
+
+	// notify tells the sender of message what happens to it, if the source can
+	notify := func(message *Message, text string) {
+		for _, source := range sources {
+			if notifier, ok := source.(Notifier); ok && source.Name() == message.Origin {
+				notifier.Notify(message, text)
+			}
+		}
+	}
+
+	for _, message := range queue.Messages() {
+		jobs.Set(message.ID, JobQueued)
+
+		for _, source := range sources {
+			if restorer, ok := source.(ReplyRestorer); ok && source.Name() == message.Origin {
+				restorer.RestoreReply(message)
+			}
+		}
+	}
+
+	// running is done on SIGINT or SIGTERM, which stops the inputs
+	running, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, source := range sources {
+		err = source.Start(running, submit)
+		if err != nil {
+			slog.Error("Failed to start the input", "source", source.Name(), "error", err)
+			return
+		}
+	}
+
+	// drained is done when the messages are not spoken any more after a signal,
+	// which is at once or after Shutdown.Drain
+	drained, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+	context.AfterFunc(running, func() {
+		slog.Info("Shutting down")
+		// another signal kills the bot at once
+		stop()
+		time.AfterFunc(settings.Shutdown.drain(), cancelDrain)
+	})
+
+	speak := func(message *Message) {
+		ctx := controller.Begin(message)
+		options := controller.Options(message.Options)
+		// a replay uses the options before the schedule changes them
+		replayOptions := options
+		prefetch()
+
+		logger := messageLogger(message).With("speaker", speakerName(options.Voice))
+		logger.Info("Speaking the message", "target", options.Target, "waited", time.Since(message.CreatedAt))
+
+		stopOnShutdown := context.AfterFunc(drained, func() {
+			notify(message, "The bot is shutting down, so the message is stopped.")
+			controller.Cancel(message.ID)
+		})
+		defer stopOnShutdown()
+
+		decision := policy.Decide(options.Priority == PriorityUrgent)
+		switch decision.Action {
+		case schedule.Hold:
+			jobs.Set(message.ID, JobHeld)
+			notify(message, decision.String())
+			logger.Info("Holding the message until the schedule opens", "until", decision.Until)
+
+			// the queue waits as well, since every message is outside the hours
+			if policy.Wait(ctx, decision.Until) != nil {
+				controller.End(message)
+				queue.Done(message)
+				finishSpoken(message, "", replayOptions, 0, nil, ErrStopped)
+				return
+			}
+		case schedule.Drop:
+			controller.End(message)
+			queue.Done(message)
+			finishSpoken(message, "", replayOptions, 0, nil, errors.New(decision.String()))
+			return
+		case schedule.Reduce:
+			if options.Volume == nil || *options.Volume > decision.Volume {
+				options.Volume = &decision.Volume
+			}
+			notify(message, decision.String())
+		}
+
+		text := normalizer.Apply(message.Text)
+		if text == "" {
+			controller.End(message)
+			queue.Done(message)
+			finishSpoken(message, "", replayOptions, 0, nil, fmt.Errorf("Nothing is left to speak after the text was normalized"))
+			return
+		}
+
+		jobs.Set(message.ID, JobSynthesizing)
+
+		synthesisStart := time.Now()
+		narration, err := narrator.Narrate(text, options, googlehomes.MaxDuration(options.Target))
 		if err != nil {
-			slackdonechan <- fmt.Errorf("Failed to play sound: %v", err)
+			controller.End(message)
+			queue.Done(message)
+			finishSpoken(message, text, replayOptions, 0, nil, fmt.Errorf("Failed to synthesize sound: %s", err))
+			return
+		}
+
+		logger.Info("Synthesized the first sentence", "latency", time.Since(synthesisStart))
+
+		var report PlayReport
+		if ctx.Err() != nil {
+			// stopped while the first sentence was synthesized
+			err = ErrStopped
+		} else {
+			jobs.Set(message.ID, JobPlaying)
+			report, err = googlehomes.Play(ctx, narration.Media, options)
+		}
+		narration.Stop()
+		if nerr := narration.Wait(); err == nil && report.Err() == nil {
+			err = nerr
+		}
+		narration.Media.Close()
+		controller.End(message)
+		queue.Done(message)
+		finishSpoken(message, text, replayOptions, narration.Duration(), report, err)
+	}
+
+	slog.Info("Start waiting messages")
+
+	for {
+		// after a signal, the rest of the queue is spoken until it is drained
+		if running.Err() != nil && (drained.Err() != nil || queue.Len() == 0) {
+			break
+		}
+
+		message := queue.Pop(running)
+		if message == nil {
 			continue
 		}
+		speak(message)
+	}
 
-		slackdonechan <- nil
+	// the messages left are spoken on the next start if the queue is saved, or dropped
+	for _, message := range queue.Messages() {
+		if settings.Queue.File != "" {
+			notify(message, "The bot is shutting down. The message will be spoken when it starts again.")
+			continue
+		}
+		if queue.Remove(message.ID) != nil {
+			finish(message, nil, ErrShutdown)
+		}
 	}
+
+	googlehomes.Close()
+	slog.Info("Shut down")
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaExtensions are the extensions of the media URLs.
+// Some receivers look at the extension as well as Content-Type.
+var mediaExtensions = map[string]string{
+	"audio/wav":  ".wav",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/webm": ".webm",
+}
+
+// MediaServer serves the synthesized sounds to the devices from memory.
+// Every sound gets a random token, which is valid until the sound is closed.
+type MediaServer struct {
+	settings MediaServerSetting
+
+	mu    sync.Mutex
+	files map[string]*MediaFile
+	// port is the port actually listened on, which is chosen by the system if Listen has no port
+	port int
+}
+
+// MediaFile is a sound published on the MediaServer.
+// A file made by NewStream grows until Finish is called,
+// and the devices loading it get the data as it is written.
+type MediaFile struct {
+	server   *MediaServer
+	token    string
+	MIMEType string
+	created  time.Time
+
+	mu       sync.Mutex
+	data     []byte
+	complete bool
+	// changed is closed and replaced whenever data grows or the file is completed
+	changed chan struct{}
+}
+
+func NewMediaServer(settings MediaServerSetting) *MediaServer {
+	return &MediaServer{
+		settings: settings,
+		files:    map[string]*MediaFile{},
+	}
+}
+
+// Start starts listening. The server is closed when ctx is done.
+func (s *MediaServer) Start(ctx context.Context) error {
+	var listen = s.settings.Listen
+	if listen == "" {
+		listen = ":0"
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("Listen: %v", err)
+	}
+
+	s.mu.Lock()
+	s.port = listener.Addr().(*net.TCPAddr).Port
+	s.mu.Unlock()
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/media/", s.serveMedia)
+
+	var server = &http.Server{Handler: mux}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Media server stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Start media server", "addr", listener.Addr().String())
+
+	return nil
+}
+
+// Publish makes data available to the devices until the returned file is closed.
+func (s *MediaServer) Publish(data []byte, mimeType string) *MediaFile {
+	var f = s.NewStream(mimeType)
+	f.Write(data)
+	f.Finish()
+	return f
+}
+
+// NewStream publishes an empty file, which grows as it is written.
+// The MIME type can be changed until the file is loaded.
+func (s *MediaServer) NewStream(mimeType string) *MediaFile {
+	var b = make([]byte, 16)
+	rand.Read(b)
+
+	var f = &MediaFile{
+		server:   s,
+		token:    hex.EncodeToString(b),
+		MIMEType: mimeType,
+		created:  time.Now(),
+		changed:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.files[f.token] = f
+	s.mu.Unlock()
+
+	return f
+}
+
+func (s *MediaServer) serveMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var name = strings.TrimPrefix(r.URL.Path, "/media/")
+	var token, _, _ = strings.Cut(name, ".")
+
+	s.mu.Lock()
+	f, ok := s.files[token]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	slog.Debug("Media server request", "remote", r.RemoteAddr, "path", r.URL.Path, "range", r.Header.Get("Range"))
+
+	w.Header().Set("Content-Type", f.MIMEType)
+	w.Header().Set("Cache-Control", "no-store")
+
+	f.mu.Lock()
+	var data, complete = f.data, f.complete
+	f.mu.Unlock()
+
+	if complete {
+		// ServeContent answers Range requests
+		http.ServeContent(w, r, name, f.created, bytes.NewReader(data))
+		return
+	}
+
+	// the length of a growing file is not known, so it is sent from the start without Range
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	var flusher, _ = w.(http.Flusher)
+	var sent = 0
+	for {
+		f.mu.Lock()
+		var data, complete, changed = f.data, f.complete, f.changed
+		f.mu.Unlock()
+
+		if sent < len(data) {
+			_, err := w.Write(data[sent:])
+			if err != nil {
+				return
+			}
+			sent = len(data)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if complete {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// baseURL returns the URL of the server seen from the device at deviceAddr.
+// Without MediaServer.URL, the address of the interface routed to the device is used.
+func (s *MediaServer) baseURL(deviceAddr string) (string, error) {
+	if s.settings.URL != "" {
+		return strings.TrimSuffix(s.settings.URL, "/"), nil
+	}
+
+	s.mu.Lock()
+	var port = s.port
+	s.mu.Unlock()
+	if port == 0 {
+		return "", fmt.Errorf("the media server is not started")
+	}
+
+	// no packet is sent, since UDP does not connect.
+	// An IPv6 address from mDNS is already in brackets.
+	conn, err := net.Dial("udp", net.JoinHostPort(strings.Trim(deviceAddr, "[]"), "8009"))
+	if err != nil {
+		return "", fmt.Errorf("unable to find the local address for %s: %v", deviceAddr, err)
+	}
+	defer conn.Close()
+
+	var local = conn.LocalAddr().(*net.UDPAddr).IP
+	return "http://" + net.JoinHostPort(local.String(), strconv.Itoa(port)), nil
+}
+
+// URL returns the URL the device at deviceAddr loads f from.
+func (f *MediaFile) URL(deviceAddr string) (string, error) {
+	base, err := f.server.baseURL(deviceAddr)
+	if err != nil {
+		return "", err
+	}
+	return base + "/media/" + f.token + mediaExtensions[f.MIMEType], nil
+}
+
+// Write appends p to f. It implements io.Writer.
+func (f *MediaFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.complete {
+		return 0, fmt.Errorf("the media file is already finished")
+	}
+
+	f.data = append(f.data, p...)
+	close(f.changed)
+	f.changed = make(chan struct{})
+
+	return len(p), nil
+}
+
+// Finish tells the devices loading f that f does not grow any more.
+func (f *MediaFile) Finish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.complete {
+		return
+	}
+	f.complete = true
+	close(f.changed)
+}
+
+// Data returns the data of f written so far.
+func (f *MediaFile) Data() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.data
+}
+
+// Close expires the token of f. The URL of f is not served any more.
+func (f *MediaFile) Close() {
+	f.Finish()
+
+	f.server.mu.Lock()
+	delete(f.server.files, f.token)
+	f.server.mu.Unlock()
+}
@@ -0,0 +1,314 @@
+// Package metrics keeps counters, gauges and histograms, and serves them in the Prometheus text format.
+// It covers what the bot needs without the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry served by the HTTP API.
+var Default = NewRegistry()
+
+// Registry is a set of metrics. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w io.Writer, name string)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s is registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// Write writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	var names []string
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	var metrics = make(map[string]metric, len(r.metrics))
+	for name, m := range r.metrics {
+		metrics[name] = m
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+
+	var bw = bufio.NewWriter(w)
+	for _, name := range names {
+		metrics[name].write(bw, name)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// vec is the values of a metric by its label values.
+type vec[T any] struct {
+	help   string
+	typ    string
+	labels []string
+	newT   func() T
+
+	mu     sync.Mutex
+	values map[string]T
+	keys   map[string][]string
+}
+
+func newVec[T any](help, typ string, labels []string, newT func() T) *vec[T] {
+	return &vec[T]{
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		newT:   newT,
+		values: map[string]T{},
+		keys:   map[string][]string{},
+	}
+}
+
+// with returns the value of labelValues, creating it if needed. v.mu must be held.
+func (v *vec[T]) with(labelValues []string) T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %d label values are given for %d labels", len(labelValues), len(v.labels)))
+	}
+
+	var key = strings.Join(labelValues, "\xff")
+	value, ok := v.values[key]
+	if !ok {
+		value = v.newT()
+		v.values[key] = value
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+	return value
+}
+
+// each calls f with the values sorted by their label values. v.mu must be held.
+func (v *vec[T]) each(f func(labelValues []string, value T)) {
+	var keys []string
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f(v.keys[key], v.values[key])
+	}
+}
+
+func (v *vec[T]) writeHeader(w io.Writer, name string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, v.typ)
+}
+
+// Counter is a value which only goes up, such as the number of errors.
+type Counter struct {
+	v *vec[*float64]
+}
+
+// NewCounter registers a counter with the names of its labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	var c = &Counter{v: newVec(help, "counter", labels, func() *float64 { return new(float64) })}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds 1 to the counter of labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter of labelValues.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: a counter cannot go down")
+	}
+
+	c.v.mu.Lock()
+	defer c.v.mu.Unlock()
+
+	*c.v.with(labelValues) += delta
+}
+
+func (c *Counter) write(w io.Writer, name string) {
+	c.v.mu.Lock()
+	defer c.v.mu.Unlock()
+
+	c.v.writeHeader(w, name)
+	c.v.each(func(labelValues []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(c.v.labels, labelValues, "", ""), formatValue(*value))
+	})
+}
+
+// Gauge is a value which goes up and down, such as the number of waiting messages.
+type Gauge struct {
+	v *vec[*float64]
+}
+
+// NewGauge registers a gauge with the names of its labels.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	var g = &Gauge{v: newVec(help, "gauge", labels, func() *float64 { return new(float64) })}
+	r.register(name, g)
+	return g
+}
+
+// Set sets the gauge of labelValues to value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.v.mu.Lock()
+	defer g.v.mu.Unlock()
+
+	*g.v.with(labelValues) = value
+}
+
+func (g *Gauge) write(w io.Writer, name string) {
+	g.v.mu.Lock()
+	defer g.v.mu.Unlock()
+
+	g.v.writeHeader(w, name)
+	g.v.each(func(labelValues []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(g.v.labels, labelValues, "", ""), formatValue(*value))
+	})
+}
+
+// funcMetric is a value without labels read when the metrics are written.
+type funcMetric struct {
+	help string
+	typ  string
+	f    func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by f, such as the length of a queue.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, &funcMetric{help: help, typ: "gauge", f: f})
+}
+
+// NewCounterFunc registers a counter whose value is returned by f, for the counters kept by other packages.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.register(name, &funcMetric{help: help, typ: "counter", f: f})
+}
+
+func (m *funcMetric) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, m.typ)
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(m.f()))
+}
+
+// Histogram counts observations such as latencies in buckets.
+type Histogram struct {
+	buckets []float64
+	v       *vec[*histogramValue]
+}
+
+type histogramValue struct {
+	// counts are the observations in each bucket, not cumulative. The last one is +Inf.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets in increasing order
+// and the names of its labels.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	var h = &Histogram{buckets: buckets}
+	h.v = newVec(help, "histogram", labels, func() *histogramValue {
+		return &histogramValue{counts: make([]uint64, len(buckets)+1)}
+	})
+	r.register(name, h)
+	return h
+}
+
+// Observe adds value to the histogram of labelValues.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+
+	var hv = h.v.with(labelValues)
+	hv.counts[sort.SearchFloat64s(h.buckets, value)]++
+	hv.sum += value
+	hv.count++
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+
+	h.v.writeHeader(w, name)
+	h.v.each(func(labelValues []string, hv *histogramValue) {
+		var cumulative uint64
+		for i, count := range hv.counts {
+			cumulative += count
+			var le = "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(h.v.labels, labelValues, "le", le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, formatLabels(h.v.labels, labelValues, "", ""), formatValue(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(h.v.labels, labelValues, "", ""), hv.count)
+	})
+}
+
+// formatLabels returns the labels such as {device="room"}, with an extra label if extraName is not empty.
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, escapeLabel(extraValue)))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testOutput(t *testing.T, r *Registry, want string) {
+	t.Helper()
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	var r = NewRegistry()
+	var c = r.NewCounter("notifier_messages_total", "Messages by source and result.", "source", "result")
+
+	c.Inc("slack", "played")
+	c.Inc("slack", "played")
+	c.Add(0.5, "http", "failed")
+	c.Inc("http", "played")
+
+	testOutput(t, r, `# HELP notifier_messages_total Messages by source and result.
+# TYPE notifier_messages_total counter
+notifier_messages_total{source="http",result="failed"} 0.5
+notifier_messages_total{source="http",result="played"} 1
+notifier_messages_total{source="slack",result="played"} 2
+`)
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	var r = NewRegistry()
+	var c = r.NewCounter("notifier_errors_total", "Errors.")
+
+	// a counter without labels has no line until it is counted
+	testOutput(t, r, `# HELP notifier_errors_total Errors.
+# TYPE notifier_errors_total counter
+`)
+
+	c.Add(1e6)
+	testOutput(t, r, `# HELP notifier_errors_total Errors.
+# TYPE notifier_errors_total counter
+notifier_errors_total 1e+06
+`)
+}
+
+func TestGauge(t *testing.T) {
+	var r = NewRegistry()
+	var g = r.NewGauge("notifier_volume", "The volume of each device.", "device")
+
+	g.Set(0.4, "living")
+	g.Set(0.8, "kitchen")
+	g.Set(0.25, "living")
+	g.Set(math.Inf(-1), "broken")
+
+	testOutput(t, r, `# HELP notifier_volume The volume of each device.
+# TYPE notifier_volume gauge
+notifier_volume{device="broken"} -Inf
+notifier_volume{device="kitchen"} 0.8
+notifier_volume{device="living"} 0.25
+`)
+}
+
+func TestGaugeFunc(t *testing.T) {
+	var r = NewRegistry()
+	var length float64
+	r.NewGaugeFunc("notifier_queue_length", "Waiting messages.", func() float64 { return length })
+	r.NewCounterFunc("notifier_cache_hits_total", "Cache hits.", func() float64 { return 7 })
+
+	length = 3
+	testOutput(t, r, `# HELP notifier_cache_hits_total Cache hits.
+# TYPE notifier_cache_hits_total counter
+notifier_cache_hits_total 7
+# HELP notifier_queue_length Waiting messages.
+# TYPE notifier_queue_length gauge
+notifier_queue_length 3
+`)
+}
+
+func TestHistogram(t *testing.T) {
+	var r = NewRegistry()
+	var h = r.NewHistogram("notifier_tts_seconds", "Time to synthesize.", []float64{0.1, 0.5, 1}, "engine")
+
+	h.Observe(0.05, "voicevox")
+	h.Observe(0.5, "voicevox") // on a bound, so in the bucket of 0.5
+	h.Observe(0.7, "voicevox")
+	h.Observe(3, "voicevox")
+	h.Observe(0.2, "command")
+
+	testOutput(t, r, `# HELP notifier_tts_seconds Time to synthesize.
+# TYPE notifier_tts_seconds histogram
+notifier_tts_seconds_bucket{engine="command",le="0.1"} 0
+notifier_tts_seconds_bucket{engine="command",le="0.5"} 1
+notifier_tts_seconds_bucket{engine="command",le="1"} 1
+notifier_tts_seconds_bucket{engine="command",le="+Inf"} 1
+notifier_tts_seconds_sum{engine="command"} 0.2
+notifier_tts_seconds_count{engine="command"} 1
+notifier_tts_seconds_bucket{engine="voicevox",le="0.1"} 1
+notifier_tts_seconds_bucket{engine="voicevox",le="0.5"} 2
+notifier_tts_seconds_bucket{engine="voicevox",le="1"} 3
+notifier_tts_seconds_bucket{engine="voicevox",le="+Inf"} 4
+notifier_tts_seconds_sum{engine="voicevox"} 4.25
+notifier_tts_seconds_count{engine="voicevox"} 4
+`)
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	var r = NewRegistry()
+	var h = r.NewHistogram("notifier_play_seconds", "Time to play.", []float64{1})
+
+	h.Observe(2)
+
+	testOutput(t, r, `# HELP notifier_play_seconds Time to play.
+# TYPE notifier_play_seconds histogram
+notifier_play_seconds_bucket{le="1"} 0
+notifier_play_seconds_bucket{le="+Inf"} 1
+notifier_play_seconds_sum 2
+notifier_play_seconds_count 1
+`)
+}
+
+func TestEscape(t *testing.T) {
+	var r = NewRegistry()
+	var c = r.NewCounter("notifier_failures_total", "Failures\nby \"reason\" and C:\\path.", "reason")
+
+	c.Inc("say \"hi\"\nat C:\\tmp")
+
+	testOutput(t, r, `# HELP notifier_failures_total Failures\nby "reason" and C:\\path.
+# TYPE notifier_failures_total counter
+notifier_failures_total{reason="say \"hi\"\nat C:\\tmp"} 1
+`)
+}
+
+func TestServeHTTP(t *testing.T) {
+	var r = NewRegistry()
+	r.NewGauge("notifier_up", "Whether the notifier is up.").Set(1)
+
+	var w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got, want := w.Body.String(), "# HELP notifier_up Whether the notifier is up.\n# TYPE notifier_up gauge\nnotifier_up 1\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	var tests = []struct {
+		name string
+		f    func(r *Registry)
+	}{
+		{"registered twice", func(r *Registry) {
+			r.NewCounter("a_total", "")
+			r.NewGauge("a_total", "")
+		}},
+		{"negative counter", func(r *Registry) {
+			r.NewCounter("a_total", "").Add(-1)
+		}},
+		{"wrong label values", func(r *Registry) {
+			r.NewCounter("a_total", "", "x", "y").Inc("only x")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.f(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ModelManager keeps track of the speakers whose models are loaded in an engine,
+// and loads a model the first time its speaker is used.
+type ModelManager struct {
+	isLoaded func(speakerID uint32) (bool, error)
+	load     func(speakerID uint32) error
+
+	// mu is held while a model is loaded, so that a model is not loaded twice at once
+	mu     sync.Mutex
+	loaded map[uint32]bool
+}
+
+func NewModelManager(isLoaded func(speakerID uint32) (bool, error), load func(speakerID uint32) error) *ModelManager {
+	return &ModelManager{
+		isLoaded: isLoaded,
+		load:     load,
+		loaded:   map[uint32]bool{},
+	}
+}
+
+// Use loads the model of speakerID unless it is loaded.
+func (m *ModelManager) Use(speakerID uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.loaded[speakerID] {
+		return nil
+	}
+
+	loaded, err := m.isLoaded(speakerID)
+	if err != nil {
+		return fmt.Errorf("IsModelLoaded: %v", err)
+	}
+	if !loaded {
+		err = m.load(speakerID)
+		if err != nil {
+			return fmt.Errorf("LoadModel: %v", err)
+		}
+	}
+
+	m.loaded[speakerID] = true
+	return nil
+}
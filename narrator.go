@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/audio"
+	"github.com/kmc-jp/GoogleHomeNotifier/normalize"
+)
+
+// Narrator synthesizes a message sentence by sentence into a stream on the media server.
+// The stream can be played as soon as the first sentence is ready,
+// and the next sentences are synthesized while the previous ones are played.
+type Narrator struct {
+	pool    *TTSPool
+	chimes  *Chimes
+	encoder *Encoder
+	media   *MediaServer
+
+	mu sync.Mutex
+	// prefetched is the ID of the message given to Prefetch last
+	prefetched string
+}
+
+func NewNarrator(pool *TTSPool, chimes *Chimes, encoder *Encoder, media *MediaServer) *Narrator {
+	return &Narrator{
+		pool:    pool,
+		chimes:  chimes,
+		encoder: encoder,
+		media:   media,
+	}
+}
+
+// Narration is a message being synthesized.
+type Narration struct {
+	Media *MediaFile
+
+	sentences int
+	spoken    int
+	duration  time.Duration
+	err       error
+	stopped   bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+// Narrate splits text into sentences and starts synthesizing them.
+// It returns when the first sentence is ready.
+// If budget is positive, the sentences after the one which would exceed it are dropped.
+func (n *Narrator) Narrate(text string, options MessageOptions, budget time.Duration) (*Narration, error) {
+	var sentences = normalize.SplitSentences(text)
+	if len(sentences) == 0 {
+		return nil, fmt.Errorf("Nothing to speak")
+	}
+
+	var synthesis = &sentenceSynthesis{pool: n.pool, sentences: sentences, options: options}
+
+	first, err := synthesis.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	first, err = n.chimes.Prepend(options.Priority, first)
+	if err != nil {
+		return nil, fmt.Errorf("Prepend: %v", err)
+	}
+
+	var narration = &Narration{
+		Media:     n.media.NewStream(""),
+		sentences: len(sentences),
+		spoken:    1,
+		duration:  first.Duration(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	stream, mimeType, err := n.encoder.NewStream(narration.Media, first.SampleRate, first.Channels)
+	if stream == nil {
+		narration.Media.Close()
+		return nil, err
+	}
+	if err != nil {
+		slog.Warn("Failed to start the encoder, so the sound is sent as WAV", "error", err)
+	}
+	narration.Media.MIMEType = mimeType
+
+	err = stream.Write(first)
+	if err != nil {
+		narration.Media.Close()
+		return nil, fmt.Errorf("Encode: %v", err)
+	}
+
+	go func() {
+		defer close(narration.done)
+		defer narration.Media.Finish()
+
+		var duration = first.Duration()
+		for range sentences[1:] {
+			select {
+			case <-narration.stop:
+				narration.stopped = true
+				narration.err = stream.Close()
+				return
+			default:
+			}
+
+			sound, err := synthesis.Next()
+			if err != nil {
+				stream.Close()
+				narration.err = err
+				return
+			}
+
+			duration += sound.Duration()
+			if budget > 0 && duration > budget {
+				break
+			}
+
+			err = stream.Write(sound)
+			if err != nil {
+				stream.Close()
+				narration.err = fmt.Errorf("Encode: %v", err)
+				return
+			}
+			narration.spoken++
+			narration.duration = duration
+		}
+
+		narration.err = stream.Close()
+	}()
+
+	return narration, nil
+}
+
+// Prefetch synthesizes the sentences of the message of id into the cache while the workers are free,
+// so that Narrate does not wait for them. A message is prefetched only once in a row.
+func (n *Narrator) Prefetch(id, text string, options MessageOptions) {
+	n.mu.Lock()
+	if n.prefetched == id {
+		n.mu.Unlock()
+		return
+	}
+	n.prefetched = id
+	n.mu.Unlock()
+
+	for _, sentence := range normalize.SplitSentences(text) {
+		n.pool.Prefetch(TtsInputAttr{Text: sentence, Voice: options.Voice, Prosody: options.Prosody})
+	}
+}
+
+// sentenceSynthesis synthesizes the sentences of a message in order.
+// As many sentences as the workers are synthesized ahead of the one being read.
+type sentenceSynthesis struct {
+	pool      *TTSPool
+	sentences []string
+	options   MessageOptions
+
+	next    int
+	pending []<-chan TtsOutputAttr
+}
+
+func (s *sentenceSynthesis) fill() {
+	for len(s.pending) < s.pool.Workers() && s.next < len(s.sentences) {
+		s.pending = append(s.pending, s.pool.Synthesize(TtsInputAttr{Text: s.sentences[s.next], Voice: s.options.Voice, Prosody: s.options.Prosody}))
+		s.next++
+	}
+}
+
+// Next returns the sound of the next sentence.
+func (s *sentenceSynthesis) Next() (*audio.PCM, error) {
+	s.fill()
+	var output = <-s.pending[0]
+	s.pending = s.pending[1:]
+	s.fill()
+
+	if output.Error != nil {
+		return nil, output.Error
+	}
+
+	sound, err := audio.DecodeWAV(output.Data)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeWAV: %v", err)
+	}
+	return sound, nil
+}
+
+// Stop stops synthesizing the rest of the sentences.
+func (n *Narration) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stop)
+	})
+}
+
+// Duration waits until the synthesis ends, and returns the length of the sound in the stream.
+func (n *Narration) Duration() time.Duration {
+	<-n.done
+	return n.duration
+}
+
+// Wait waits until the synthesis ends.
+// It returns an error if a sentence failed or the message was cut to fit the budget.
+func (n *Narration) Wait() error {
+	<-n.done
+
+	if n.err != nil {
+		return fmt.Errorf("Failed to synthesize sound: %v", n.err)
+	}
+	if n.spoken < n.sentences && !n.stopped {
+		return fmt.Errorf("The message was too long, so only %d of %d sentences were spoken.", n.spoken, n.sentences)
+	}
+	return nil
+}
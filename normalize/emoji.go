@@ -0,0 +1,172 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var emojiRegexp = regexp.MustCompile(`:([a-z0-9_+\-']+):(?::skin-tone-\d:)?`)
+
+// emojiNames are the readings of common Slack emoji.
+var emojiNames = map[string]string{
+	"+1":                         "いいね",
+	"thumbsup":                   "いいね",
+	"-1":                         "よくないね",
+	"thumbsdown":                 "よくないね",
+	"smile":                      "にっこり",
+	"smiley":                     "にっこり",
+	"grin":                       "にやり",
+	"laughing":                   "笑",
+	"joy":                        "嬉し泣き",
+	"sob":                        "号泣",
+	"cry":                        "涙",
+	"innocent":                   "天使",
+	"thinking_face":              "考え中",
+	"sweat_smile":                "汗",
+	"sweat":                      "汗",
+	"scream":                     "叫び",
+	"pray":                       "お願い",
+	"clap":                       "拍手",
+	"tada":                       "おめでとう",
+	"heart":                      "ハート",
+	"fire":                       "炎上",
+	"eyes":                       "目",
+	"ok":                         "オーケー",
+	"ok_hand":                    "オーケー",
+	"wave":                       "バイバイ",
+	"bow":                        "ぺこり",
+	"muscle":                     "力こぶ",
+	"sunny":                      "晴れ",
+	"cloud":                      "くもり",
+	"umbrella":                   "雨",
+	"snowman":                    "雪だるま",
+	"coffee":                     "コーヒー",
+	"beer":                       "ビール",
+	"beers":                      "乾杯",
+	"sushi":                      "寿司",
+	"ramen":                      "ラーメン",
+	"rice_ball":                  "おにぎり",
+	"cake":                       "ケーキ",
+	"birthday":                   "誕生日ケーキ",
+	"warning":                    "注意",
+	"rotating_light":             "緊急",
+	"bell":                       "ベル",
+	"white_check_mark":           "完了",
+	"heavy_check_mark":           "チェック",
+	"x":                          "バツ",
+	"o":                          "マル",
+	"question":                   "はてな",
+	"exclamation":                "びっくり",
+	"bulb":                       "ひらめき",
+	"memo":                       "メモ",
+	"calendar":                   "カレンダー",
+	"rocket":                     "ロケット",
+	"sparkles":                   "キラキラ",
+	"star":                       "星",
+	"zzz":                        "すやすや",
+	"dog":                        "犬",
+	"cat":                        "猫",
+	"hourglass_flowing_sand":     "待機中",
+	"arrow_right":                "右",
+	"arrow_left":                 "左",
+	"100":                        "満点",
+	"bangbang":                   "びっくり",
+	"interrobang":                "びっくりはてな",
+	"heavy_exclamation_mark":     "びっくり",
+	"see_no_evil":                "見ざる",
+	"slightly_smiling_face":      "にっこり",
+	"face_with_rolling_eyes":     "呆れ顔",
+	"exploding_head":             "衝撃",
+	"partying_face":              "お祝い",
+	"raised_hands":               "ばんざい",
+	"man-bowing":                 "ぺこり",
+	"woman-bowing":               "ぺこり",
+	"heavy_plus_sign":            "プラス",
+	"heavy_minus_sign":           "マイナス",
+	"confused":                   "困惑",
+	"neutral_face":               "無表情",
+	"upside_down_face":           "逆さま",
+	"smirk":                      "にやり",
+	"kissing_heart":              "投げキッス",
+	"heart_eyes":                 "目がハート",
+	"rage":                       "激怒",
+	"angry":                      "怒り",
+	"wink":                       "ウインク",
+	"stuck_out_tongue":           "あっかんべー",
+	"pensive":                    "しょんぼり",
+	"relieved":                   "ほっ",
+	"dizzy_face":                 "目が回る",
+	"zipper_mouth_face":          "お口チャック",
+	"sunglasses":                 "サングラス",
+	"nerd_face":                  "オタク",
+	"money_with_wings":           "散財",
+	"moneybag":                   "お金",
+	"gift":                       "プレゼント",
+	"soon":                       "もうすぐ",
+	"new":                        "新着",
+	"free":                       "無料",
+	"sos":                        "エスオーエス",
+	"no_entry":                   "進入禁止",
+	"no_entry_sign":              "禁止",
+	"construction":               "工事中",
+	"bug":                        "バグ",
+	"computer":                   "パソコン",
+	"email":                      "メール",
+	"telephone_receiver":         "電話",
+	"pushpin":                    "ピン留め",
+	"link":                       "リンク",
+	"lock":                       "鍵",
+	"key":                        "鍵",
+	"bento":                      "弁当",
+	"curry":                      "カレー",
+	"pizza":                      "ピザ",
+	"hamburger":                  "ハンバーガー",
+	"tea":                        "お茶",
+	"sake":                       "日本酒",
+	"house":                      "家",
+	"train":                      "電車",
+	"bike":                       "自転車",
+	"car":                        "車",
+	"airplane":                   "飛行機",
+	"clock":                      "時計",
+	"alarm_clock":                "目覚まし",
+	"japan":                      "日本",
+	"jp":                         "日本",
+	"ghost":                      "おばけ",
+	"skull":                      "ドクロ",
+	"poop":                       "うんち",
+	"hankey":                     "うんち",
+	"robot_face":                 "ロボット",
+	"trophy":                     "トロフィー",
+	"medal":                      "メダル",
+	"first_place_medal":          "金メダル",
+	"chart_with_upwards_trend":   "上昇",
+	"chart_with_downwards_trend": "下降",
+}
+
+// Emoji returns the rule reading emoji such as :tada: by their Japanese names.
+// names are added to and override the built-in names.
+// Unknown emoji are removed, and skin tones are ignored.
+func Emoji(names map[string]string) Rule {
+	return func(text string) string {
+		return emojiRegexp.ReplaceAllStringFunc(text, func(m string) string {
+			var name = emojiRegexp.FindStringSubmatch(m)[1]
+			if reading, ok := names[name]; ok {
+				return reading
+			}
+			if reading, ok := emojiNames[name]; ok {
+				return reading
+			}
+
+			// "12:30:45" is not an emoji
+			if strings.IndexFunc(name, isLetter) < 0 {
+				return m
+			}
+			return ""
+		})
+	}
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z'
+}
@@ -0,0 +1,57 @@
+package normalize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var acronymRegexp = regexp.MustCompile(`\b[A-Z]{2,6}\b`)
+
+// letterNames are the readings of the alphabet.
+var letterNames = map[rune]string{
+	'A': "エー", 'B': "ビー", 'C': "シー", 'D': "ディー", 'E': "イー", 'F': "エフ", 'G': "ジー",
+	'H': "エイチ", 'I': "アイ", 'J': "ジェー", 'K': "ケー", 'L': "エル", 'M': "エム", 'N': "エヌ",
+	'O': "オー", 'P': "ピー", 'Q': "キュー", 'R': "アール", 'S': "エス", 'T': "ティー", 'U': "ユー",
+	'V': "ブイ", 'W': "ダブリュー", 'X': "エックス", 'Y': "ワイ", 'Z': "ゼット",
+}
+
+// English returns the rule reading the English words in words as their readings.
+// The words are matched as whole words ignoring case, and longer words first.
+// If spellAcronyms is true, the other words of capital letters are read letter by letter.
+func English(words map[string]string, spellAcronyms bool) Rule {
+	var readings = map[string]string{}
+	var keys []string
+	for word, reading := range words {
+		readings[strings.ToLower(word)] = reading
+		keys = append(keys, regexp.QuoteMeta(word))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+
+	var wordsRegexp *regexp.Regexp
+	if len(keys) > 0 {
+		wordsRegexp = regexp.MustCompile(`(?i)\b(?:` + strings.Join(keys, "|") + `)\b`)
+	}
+
+	return func(text string) string {
+		if wordsRegexp != nil {
+			text = wordsRegexp.ReplaceAllStringFunc(text, func(m string) string {
+				return readings[strings.ToLower(m)]
+			})
+		}
+
+		if spellAcronyms {
+			text = acronymRegexp.ReplaceAllStringFunc(text, func(m string) string {
+				var b strings.Builder
+				for _, r := range m {
+					b.WriteString(letterNames[r])
+				}
+				return b.String()
+			})
+		}
+
+		return text
+	}
+}
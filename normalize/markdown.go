@@ -0,0 +1,21 @@
+package normalize
+
+import (
+	"regexp"
+)
+
+var (
+	boldRegexp   = regexp.MustCompile(`(^|\s)\*([^*\n]+)\*`)
+	italicRegexp = regexp.MustCompile(`(^|\s)_([^_\n]+)_`)
+	strikeRegexp = regexp.MustCompile(`(^|\s)~([^~\n]+)~`)
+	headRegexp   = regexp.MustCompile(`(?m)^\s*(?:>+|#+|[-*•]|\d+\.)\s+`)
+)
+
+// Markdown removes the markers of Slack mrkdwn and Markdown:
+// bold, italic and strikethrough, quotes, headings and list items.
+func Markdown(text string) string {
+	text = boldRegexp.ReplaceAllString(text, "$1$2")
+	text = italicRegexp.ReplaceAllString(text, "$1$2")
+	text = strikeRegexp.ReplaceAllString(text, "$1$2")
+	return headRegexp.ReplaceAllString(text, "")
+}
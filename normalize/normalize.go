@@ -0,0 +1,142 @@
+// Package normalize rewrites chat texts into texts which read well aloud.
+// A Pipeline is a list of Rules, each of which is a plain function on strings.
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule rewrites a text.
+type Rule func(text string) string
+
+// Pipeline applies its rules in order.
+type Pipeline []Rule
+
+// Apply returns text rewritten by every rule.
+func (p Pipeline) Apply(text string) string {
+	for _, rule := range p {
+		text = rule(text)
+	}
+	return strings.TrimSpace(text)
+}
+
+// DefaultRules are the names of the rules used when Options.Rules is empty, in order.
+// Slack markup has to be read before the HTML entities are unescaped,
+// and URLs have to be replaced before emoji and times, which look like parts of URLs.
+var DefaultRules = []string{"slack", "code", "html", "markdown", "url", "emoji", "date", "number", "english", "substitution"}
+
+type URLMode string
+
+const (
+	// URLLink reads a URL as Options.LinkWord
+	URLLink URLMode = "link"
+	// URLDomain reads a URL as its domain
+	URLDomain URLMode = "domain"
+	// URLKeep leaves a URL as it is
+	URLKeep URLMode = "keep"
+)
+
+// Substitution replaces the matches of the regular expression Pattern with Replace.
+// Replace can refer to the submatches as $1.
+type Substitution struct {
+	Pattern string
+	Replace string
+}
+
+type Options struct {
+	// Rules are the names of the rules to apply in order. DefaultRules is used if it is empty.
+	Rules []string
+	// URL is how URLs are read. The default is URLLink.
+	URL URLMode
+	// LinkWord is the word a URL is read as with URLLink. The default is "リンク".
+	LinkWord string
+	// CodeBlock is the word a code block is read as. The default is "コード省略".
+	CodeBlock string
+	// Emoji are read as these names in addition to the built-in ones. The keys have no colons.
+	Emoji map[string]string
+	// English are the readings of English words. The words are matched ignoring case.
+	English map[string]string
+	// SpellAcronyms reads words of capital letters, such as "API", letter by letter.
+	SpellAcronyms bool
+	// Substitutions are applied by the "substitution" rule in order.
+	Substitutions []Substitution
+}
+
+// New returns the pipeline of options.Rules.
+func New(options Options) (Pipeline, error) {
+	var names = options.Rules
+	if len(names) == 0 {
+		names = DefaultRules
+	}
+
+	var pipeline Pipeline
+	for _, name := range names {
+		var rule Rule
+		switch name {
+		case "slack":
+			rule = SlackMarkup
+		case "code":
+			rule = CodeBlocks(withDefault(options.CodeBlock, "コード省略"))
+		case "html":
+			rule = HTMLEntities
+		case "markdown":
+			rule = Markdown
+		case "url":
+			switch options.URL {
+			case "", URLLink:
+				rule = URLs(URLLink, withDefault(options.LinkWord, "リンク"))
+			case URLDomain, URLKeep:
+				rule = URLs(options.URL, "")
+			default:
+				return nil, fmt.Errorf("unknown URL mode %q", options.URL)
+			}
+		case "emoji":
+			rule = Emoji(options.Emoji)
+		case "date":
+			rule = Dates
+		case "number":
+			rule = Numbers
+		case "english":
+			rule = English(options.English, options.SpellAcronyms)
+		case "substitution":
+			var err error
+			rule, err = Substitutions(options.Substitutions)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		pipeline = append(pipeline, rule)
+	}
+
+	return pipeline, nil
+}
+
+// Substitutions returns the rule applying subs in order.
+func Substitutions(subs []Substitution) (Rule, error) {
+	var patterns = make([]*regexp.Regexp, len(subs))
+	for i, sub := range subs {
+		var err error
+		patterns[i], err = regexp.Compile(sub.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("substitution %q: %v", sub.Pattern, err)
+		}
+	}
+
+	return func(text string) string {
+		for i, pattern := range patterns {
+			text = pattern.ReplaceAllString(text, subs[i].Replace)
+		}
+		return text
+	}, nil
+}
+
+func withDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
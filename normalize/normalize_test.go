@@ -0,0 +1,212 @@
+package normalize
+
+import (
+	"testing"
+)
+
+type ruleTest struct {
+	in, want string
+}
+
+func testRule(t *testing.T, rule Rule, tests []ruleTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		if got := rule(tt.in); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSlackMarkup(t *testing.T) {
+	testRule(t, SlackMarkup, []ruleTest{
+		{"<#C0123ABCD|general> に集合", "general に集合"},
+		{"<#C0123ABCD> に集合", " に集合"},
+		{"<https://example.com/a?b=c|資料> を見て", "資料 を見て"},
+		{"<https://example.com/a>", "https://example.com/a"},
+		{"<mailto:a@example.com|a@example.com>", "a@example.com"},
+		{"<mailto:a@example.com>", "a@example.com"},
+		{"<!here> 会議です", "here 会議です"},
+		{"<!subteam^S0123|@kmc> 集合", "@kmc 集合"},
+		{"<@U0123> さん", "<@U0123> さん"},
+		{"<@U0123|tanaka> さん", "tanaka さん"},
+		{"1 < 2 > 0", "1 < 2 > 0"},
+	})
+}
+
+func TestCodeBlocks(t *testing.T) {
+	testRule(t, CodeBlocks("コード省略"), []ruleTest{
+		{"見て ```go\nfmt.Println(1)\n``` です", "見て コード省略 です"},
+		{"```a``` と ```b```", "コード省略 と コード省略"},
+		{"`make build` を実行", "make build を実行"},
+		{"閉じない ``` です", "閉じない ``` です"},
+	})
+}
+
+func TestHTMLEntities(t *testing.T) {
+	testRule(t, HTMLEntities, []ruleTest{
+		{"A &amp; B", "A & B"},
+		{"&lt;tag&gt;", "<tag>"},
+		{"&amp;amp;", "&amp;"},
+		{"そのまま", "そのまま"},
+	})
+}
+
+func TestMarkdown(t *testing.T) {
+	testRule(t, Markdown, []ruleTest{
+		{"*重要* なお知らせ", "重要 なお知らせ"},
+		{"これは _斜体_ と ~取り消し~", "これは 斜体 と 取り消し"},
+		{"> 引用です", "引用です"},
+		{"# 見出し\n- 項目1\n1. 項目2", "見出し\n項目1\n項目2"},
+		{"2*3*4", "2*3*4"},
+		{"snake_case_name", "snake_case_name"},
+	})
+}
+
+func TestURLs(t *testing.T) {
+	var text = "詳細は https://www.example.com/path?q=1 を見て"
+
+	testRule(t, URLs(URLLink, "リンク"), []ruleTest{{text, "詳細は リンク を見て"}})
+	testRule(t, URLs(URLDomain, ""), []ruleTest{{text, "詳細は example.com を見て"}})
+	testRule(t, URLs(URLKeep, ""), []ruleTest{{text, text}})
+}
+
+func TestEmoji(t *testing.T) {
+	testRule(t, Emoji(map[string]string{"kmc": "ケーエムシー", "tada": "やったね"}), []ruleTest{
+		{"リリース :rocket:", "リリース ロケット"},
+		{":+1::skin-tone-3:", "いいね"},
+		{":kmc: 集合", "ケーエムシー 集合"},
+		{":tada:", "やったね"},
+		{":unknown_emoji: です", " です"},
+		{"12:30:45 に", "12:30:45 に"},
+	})
+
+	testRule(t, Emoji(nil), []ruleTest{
+		{":tada: 合格", "おめでとう 合格"},
+	})
+}
+
+func TestDates(t *testing.T) {
+	testRule(t, Dates, []ruleTest{
+		{"2024-05-01 に", "2024年5月1日 に"},
+		{"2024/12/31", "2024年12月31日"},
+		{"2024.1.9", "2024年1月9日"},
+		{"2024-13-01", "2024-13-01"},
+		{"12:30 に集合", "12時30分 に集合"},
+		{"9:00 から", "9時 から"},
+		{"12:30:45", "12時30分45秒"},
+		{"12:00:45", "12時0分45秒"},
+		{"12:30:00", "12時30分"},
+		{"24:00", "24時"},
+		{"25:00", "25:00"},
+		{"12:60", "12:60"},
+	})
+}
+
+func TestNumbers(t *testing.T) {
+	testRule(t, Numbers, []ruleTest{
+		{"1,000円", "1000円"},
+		{"12,345,678人", "12345678人"},
+		{"1,2,3", "1,2,3"},
+		{"50%", "50パーセント"},
+		{"80 ％ 完了", "80パーセント 完了"},
+		{"3~5人", "3から5人"},
+		{"10 〜 20", "10から20"},
+	})
+}
+
+func TestEnglish(t *testing.T) {
+	var words = map[string]string{
+		"Slack":       "スラック",
+		"Google":      "グーグル",
+		"Google Home": "グーグルホーム",
+	}
+
+	testRule(t, English(words, false), []ruleTest{
+		{"slack で通知", "スラック で通知"},
+		{"Google Home から", "グーグルホーム から"},
+		{"Googleで検索", "グーグルで検索"},
+		{"Google で検索", "グーグル で検索"},
+		{"Slackbot", "Slackbot"},
+		{"API を呼ぶ", "API を呼ぶ"},
+	})
+
+	testRule(t, English(words, true), []ruleTest{
+		{"API を呼ぶ", "エーピーアイ を呼ぶ"},
+		{"KMC の Slack", "ケーエムシー の スラック"},
+		{"ABCDEFG", "ABCDEFG"},
+		{"Api", "Api"},
+	})
+}
+
+func TestSubstitutions(t *testing.T) {
+	rule, err := Substitutions([]Substitution{
+		{Pattern: `部室`, Replace: "ぶしつ"},
+		{Pattern: `(\d+)F`, Replace: "${1}階"},
+		{Pattern: `ぶしつ`, Replace: "部屋"},
+	})
+	if err != nil {
+		t.Fatalf("Substitutions: %v", err)
+	}
+
+	// applied in order
+	testRule(t, rule, []ruleTest{
+		{"3F の部室", "3階 の部屋"},
+		{"そのまま", "そのまま"},
+	})
+
+	_, err = Substitutions([]Substitution{{Pattern: `(`}})
+	if err == nil {
+		t.Error("Substitutions accepted an invalid pattern")
+	}
+}
+
+func TestNew(t *testing.T) {
+	pipeline, err := New(Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if len(pipeline) != len(DefaultRules) {
+		t.Errorf("%d rules, want the %d DefaultRules", len(pipeline), len(DefaultRules))
+	}
+
+	for _, options := range []Options{
+		{Rules: []string{"slack", "unknown"}},
+		{URL: "short"},
+		{Substitutions: []Substitution{{Pattern: `[`}}},
+	} {
+		_, err := New(options)
+		if err == nil {
+			t.Errorf("New(%+v) succeeded", options)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	pipeline, err := New(Options{
+		English:       map[string]string{"deploy": "デプロイ"},
+		SpellAcronyms: true,
+		Substitutions: []Substitution{{Pattern: `本番`, Replace: "ほんばん"}},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	testRule(t, pipeline.Apply, []ruleTest{
+		{"<!here> *本番* deploy は 2024-05-01 12:30 から :tada:", "here ほんばん デプロイ は 2024年5月1日 12時30分 から おめでとう"},
+		{"<#C0123|random> &amp; <https://example.com|資料> を見て", "random & 資料 を見て"},
+		{"詳細 https://example.com/a?b=1#c", "詳細 リンク"},
+		{"```\ncode\n``` の結果は 1,000 件で 50% が API エラー", "コード省略 の結果は 1000 件で 50パーセント が エーピーアイ エラー"},
+		{"&lt;https://example.com&gt;", "<リンク>"},
+		{"  :unknown:  ", ""},
+	})
+
+	// rules can be chosen and ordered
+	pipeline, err = New(Options{Rules: []string{"html", "url"}, URL: URLDomain})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	testRule(t, pipeline.Apply, []ruleTest{
+		{"*A* &amp; https://www.example.com/x :tada:", "*A* & example.com :tada:"},
+	})
+}
@@ -0,0 +1,60 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	dateRegexp      = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	timeRegexp      = regexp.MustCompile(`\b(\d{1,2}):(\d{2})(?::(\d{2}))?\b`)
+	separatorRegexp = regexp.MustCompile(`\d{1,3}(?:,\d{3})+`)
+	percentRegexp   = regexp.MustCompile(`(\d)\s*[%％]`)
+	rangeRegexp     = regexp.MustCompile(`(\d)\s*[~〜～]\s*(\d)`)
+)
+
+// Dates reads dates such as 2024-05-01 and times such as 12:30 in Japanese.
+func Dates(text string) string {
+	text = dateRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		var s = dateRegexp.FindStringSubmatch(m)
+		var month, day = atoi(s[2]), atoi(s[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return m
+		}
+		return s[1] + "年" + strconv.Itoa(month) + "月" + strconv.Itoa(day) + "日"
+	})
+
+	return timeRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		var s = timeRegexp.FindStringSubmatch(m)
+		var hour, minute = atoi(s[1]), atoi(s[2])
+		if hour > 24 || minute > 59 {
+			return m
+		}
+
+		var b strings.Builder
+		b.WriteString(strconv.Itoa(hour) + "時")
+		if minute > 0 || (s[3] != "" && atoi(s[3]) > 0) {
+			b.WriteString(strconv.Itoa(minute) + "分")
+		}
+		if s[3] != "" && atoi(s[3]) > 0 {
+			b.WriteString(strconv.Itoa(atoi(s[3])) + "秒")
+		}
+		return b.String()
+	})
+}
+
+// Numbers removes the thousands separators, so that 1,000 is read as a number,
+// and reads % and ranges such as 3~5.
+func Numbers(text string) string {
+	text = separatorRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		return strings.ReplaceAll(m, ",", "")
+	})
+	text = percentRegexp.ReplaceAllString(text, "${1}パーセント")
+	return rangeRegexp.ReplaceAllString(text, "${1}から${2}")
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,42 @@
+package normalize
+
+import (
+	"strings"
+)
+
+// sentenceEnds are the characters after which a sentence ends.
+const sentenceEnds = "。！？!?\n"
+
+// SplitSentences splits text after 。！？ and newlines.
+// The punctuation stays with the sentence, and blank sentences are dropped.
+// Closing brackets and repeated marks such as "！？" stay with the sentence before them.
+func SplitSentences(text string) []string {
+	var sentences []string
+	var current strings.Builder
+
+	var runes = []rune(text)
+	for i := 0; i < len(runes); i++ {
+		current.WriteRune(runes[i])
+
+		if !strings.ContainsRune(sentenceEnds, runes[i]) {
+			continue
+		}
+		for i+1 < len(runes) && strings.ContainsRune(sentenceEnds+"」』）)", runes[i+1]) {
+			i++
+			current.WriteRune(runes[i])
+		}
+
+		sentences = appendSentence(sentences, current.String())
+		current.Reset()
+	}
+
+	return appendSentence(sentences, current.String())
+}
+
+func appendSentence(sentences []string, s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return sentences
+	}
+	return append(sentences, s)
+}
@@ -0,0 +1,34 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"こんにちは", []string{"こんにちは"}},
+		{"こんにちは。今日は晴れです。", []string{"こんにちは。", "今日は晴れです。"}},
+		{"本当！？ すごい!!", []string{"本当！？", "すごい!!"}},
+		{"え？！？！ まさか", []string{"え？！？！", "まさか"}},
+		{"彼は「行く。」と言った。", []string{"彼は「行く。」", "と言った。"}},
+		{"（休み！）明日は『雨。』", []string{"（休み！）", "明日は『雨。』"}},
+		{"test (done!) ok", []string{"test (done!)", "ok"}},
+		{"一行目\n二行目", []string{"一行目", "二行目"}},
+		{"一行目\n\n\n二行目\n", []string{"一行目", "二行目"}},
+		{"  \n 。\n  ", []string{"。"}},
+		{"終わり。\n次の段落", []string{"終わり。", "次の段落"}},
+		{"続く文 ", []string{"続く文"}},
+	}
+
+	for _, tt := range tests {
+		got := SplitSentences(tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitSentences(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package normalize
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	slackMarkupRegexp = regexp.MustCompile(`<([^<>\s]+)>`)
+	codeBlockRegexp   = regexp.MustCompile("(?s)```.*?```")
+	inlineCodeRegexp  = regexp.MustCompile("`([^`\n]+)`")
+	urlRegexp         = regexp.MustCompile(`https?://[^\s<>"]+`)
+)
+
+// SlackMarkup replaces the links of Slack with their labels.
+//
+//	<#C0123|general>          general
+//	<!subteam^S0123|@kmc>     @kmc
+//	<!here>                   here
+//	<https://example.com|例>  例
+//	<https://example.com>     https://example.com
+//
+// The user mentions <@U0123> are left, since their names have to be looked up.
+func SlackMarkup(text string) string {
+	return slackMarkupRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		var inner = m[1 : len(m)-1]
+
+		target, label, hasLabel := strings.Cut(inner, "|")
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if hasLabel {
+				return label
+			}
+			return m
+		case hasLabel:
+			return label
+		case strings.HasPrefix(target, "!"):
+			return strings.TrimPrefix(target, "!")
+		case strings.HasPrefix(target, "#"):
+			return ""
+		case strings.HasPrefix(target, "mailto:"):
+			return strings.TrimPrefix(target, "mailto:")
+		}
+		return target
+	})
+}
+
+// CodeBlocks returns the rule reading a code block as word.
+// The backquotes of inline codes are removed.
+func CodeBlocks(word string) Rule {
+	return func(text string) string {
+		text = codeBlockRegexp.ReplaceAllLiteralString(text, word)
+		return inlineCodeRegexp.ReplaceAllString(text, "$1")
+	}
+}
+
+// HTMLEntities unescapes HTML entities such as &amp;.
+func HTMLEntities(text string) string {
+	return html.UnescapeString(text)
+}
+
+// URLs returns the rule reading URLs by mode.
+func URLs(mode URLMode, linkWord string) Rule {
+	return func(text string) string {
+		switch mode {
+		case URLKeep:
+			return text
+		case URLDomain:
+			return urlRegexp.ReplaceAllStringFunc(text, func(m string) string {
+				u, err := url.Parse(m)
+				if err != nil || u.Hostname() == "" {
+					return linkWord
+				}
+				return strings.TrimPrefix(u.Hostname(), "www.")
+			})
+		}
+		return urlRegexp.ReplaceAllLiteralString(text, linkWord)
+	}
+}
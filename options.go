@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MessageOptions are per-message settings which override the defaults in settings.
+type MessageOptions struct {
+	// Voice is a VOICEVOX speaker name or ID (e.g. "ずんだもん", "ずんだもん/あまあま", "3")
+	Voice string `json:"voice,omitempty"`
+	// Priority decides the order in the queue
+	Priority Priority `json:"priority"`
+	// Target is a comma separated list of device names or group names, or "all"
+	Target string `json:"target,omitempty"`
+	// Volume overrides GoogleHomeSetting.Volume
+	Volume *float32 `json:"volume,omitempty"`
+	// Prosody overrides VoicevoxSetting.Prosody
+	Prosody Prosody `json:"prosody"`
+}
+
+// ParseMessageOptions takes "key:value" tokens off the head of text.
+// e.g. "voice:ずんだもん こんにちは" sets Voice to "ずんだもん" and returns "こんにちは".
+// Parsing stops at the first token which is not a known option.
+func ParseMessageOptions(text string) (MessageOptions, string) {
+	var options MessageOptions
+
+	text = strings.TrimSpace(text)
+	for text != "" {
+		token, rest := text, ""
+		if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+			token, rest = text[:i], text[i:]
+		}
+
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" {
+			break
+		}
+
+		switch strings.ToLower(key) {
+		case "voice":
+			options.Voice = value
+		case "priority":
+			priority, err := ParsePriority(strings.ToLower(value))
+			if err != nil {
+				return options, text
+			}
+			options.Priority = priority
+		case "room":
+			options.Target = value
+		case "volume":
+			volume, err := strconv.ParseFloat(value, 32)
+			if err != nil || volume < 0 || volume > 1 {
+				return options, text
+			}
+			var v = float32(volume)
+			options.Volume = &v
+		case "speed", "pitch", "intonation", "volumescale", "prepause", "postpause":
+			f, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return options, text
+			}
+			var v = float32(f)
+			var prosody Prosody
+			switch strings.ToLower(key) {
+			case "speed":
+				prosody.SpeedScale = &v
+			case "pitch":
+				prosody.PitchScale = &v
+			case "intonation":
+				prosody.IntonationScale = &v
+			case "volumescale":
+				prosody.VolumeScale = &v
+			case "prepause":
+				prosody.PrePhonemeLength = &v
+			case "postpause":
+				prosody.PostPhonemeLength = &v
+			}
+			// a value out of range is left in the text, as volume is
+			if prosody.Validate() != nil {
+				return options, text
+			}
+			options.Prosody = options.Prosody.Merge(prosody)
+		default:
+			return options, text
+		}
+
+		text = strings.TrimSpace(rest)
+	}
+
+	return options, text
+}
@@ -1,56 +1,126 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net"
 	"time"
 
 	"github.com/vishen/go-chromecast/application"
 )
 
-func Play(sound TtsOutputAttr, settings GoogleHomeSetting) error {
-	applicationOptions := []application.ApplicationOption{
-		application.WithDebug(DEBUG),
+// maxDurationGrace is the time a device may take to start playing.
+const maxDurationGrace = 2 * time.Second
+
+// Play plays media on the device and waits until it finishes, MaxDuration passes or ctx is done.
+// Afterwards, the volume is restored, and in the duck mode, the media played before is resumed.
+// The connection to the device is kept for the next message.
+func Play(ctx context.Context, media *MediaFile, settings GoogleHomeSetting, conn *CastConnection) error {
+	return conn.Do(func(app *application.Application, addr string) error {
+		return play(ctx, app, addr, media, settings)
+	})
+}
+
+func play(ctx context.Context, app *application.Application, addr string, media *MediaFile, settings GoogleHomeSetting) error {
+	url, err := media.URL(addr)
+	if err != nil {
+		return fmt.Errorf("URL: %v", err)
 	}
 
-	var iface *net.Interface
-	if settings.Iface != "" {
-		var err error
-		if iface, err = net.InterfaceByName(settings.Iface); err != nil {
-			return fmt.Errorf("unable to find interface %q: %v", settings.Iface, err)
+	var mode = settings.playMode()
+
+	if mode == PlayModeWait {
+		err = waitForIdle(ctx, app, settings.waitTimeout())
+		if err != nil {
+			return err
 		}
-		applicationOptions = append(applicationOptions, application.WithIface(iface))
 	}
 
-	app := application.NewApplication(applicationOptions...)
-	err := app.Start(settings.Addr, settings.Port)
+	session := captureSession(app)
+
+	err = app.SetVolume(settings.Volume)
 	if err != nil {
-		return fmt.Errorf("Start: %v", err)
+		return fmt.Errorf("SetVolume: %v", err)
+	}
+	if session.Muted {
+		app.SetMuted(false)
 	}
 
-	volume := app.Volume().Level
-	app.SetVolume(settings.Volume)
-
-	err = app.Load(sound.FilePath, 0, "audio/wav", false, settings.Detach, settings.ForceDetach)
+	// the media is served by our server, so Load returns without waiting and the end is waited below
+	err = app.Load(url, 0, media.MIMEType, false, true, false)
 	if err != nil {
-		return fmt.Errorf("Load: %v", err)
+		return joinRestoreError(fmt.Errorf("Load: %v", err), session.restore(app, false))
+	}
+
+	// the message is cut to fit MaxDuration before it is played,
+	// so the timer only stops a sentence which is too long by itself
+	var timeout <-chan time.Time
+	if settings.maxDuration() > 0 {
+		timer := time.NewTimer(settings.maxDuration() + maxDurationGrace)
+		defer timer.Stop()
+		timeout = timer.C
 	}
 
-	timer := time.NewTimer(time.Second * time.Duration(settings.MaxDuration))
-	stopchan := make(chan bool)
+	stopchan := make(chan bool, 1)
 
 	go func() {
 		app.MediaWait()
 		stopchan <- true
 	}()
 
+	var playErr error
 	select {
-	case <-timer.C:
+	case <-timeout:
+		app.StopMedia()
+		playErr = fmt.Errorf("The message was too long, so it was interrupted.")
+	case <-ctx.Done():
 		app.StopMedia()
-		app.SetVolume(volume)
-		return fmt.Errorf("The message was too long, so it was interrupted.")
+		playErr = ErrStopped
 	case <-stopchan:
-		app.SetVolume(volume)
-		return nil
 	}
+
+	return joinRestoreError(playErr, session.restore(app, mode == PlayModeDuck))
+}
+
+// joinRestoreError returns err with the error of the restoration.
+func joinRestoreError(err, restoreErr error) error {
+	switch {
+	case restoreErr == nil:
+		return err
+	case err == nil:
+		return fmt.Errorf("Failed to restore the device: %v", restoreErr)
+	}
+	return fmt.Errorf("%v (and failed to restore the device: %v)", err, restoreErr)
+}
+
+// startApplication connects to the resolved device.
+// If the connection fails, the device may have got a new address,
+// so the device is resolved once more and the connection is retried.
+// newApplication is called for each try. The address of the device is returned as well.
+func startApplication(resolver *DeviceResolver, newApplication func() *application.Application) (*application.Application, string, error) {
+	addr, port, err := resolver.Resolve()
+	if err != nil {
+		return nil, "", fmt.Errorf("Resolve: %v", err)
+	}
+
+	app := newApplication()
+	err = app.Start(addr, port)
+	if err == nil {
+		return app, addr, nil
+	}
+
+	resolver.Invalidate()
+
+	newAddr, newPort, rerr := resolver.Resolve()
+	if rerr != nil || (newAddr == addr && newPort == port) {
+		return nil, "", fmt.Errorf("Start: %v", err)
+	}
+
+	app = newApplication()
+	err = app.Start(newAddr, newPort)
+	if err != nil {
+		resolver.Invalidate()
+		return nil, "", fmt.Errorf("Start: %v", err)
+	}
+
+	return app, newAddr, nil
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+// prosodyRange is the range of a field of Prosody.
+type prosodyRange struct {
+	min, max float32
+}
+
+// The ranges are the ones of the VOICEVOX editor.
+// They keep a message from being too slow or too long to play.
+var (
+	speedScaleRange        = prosodyRange{0.5, 2}
+	pitchScaleRange        = prosodyRange{-0.15, 0.15}
+	intonationScaleRange   = prosodyRange{0, 2}
+	volumeScaleRange       = prosodyRange{0, 2}
+	prePhonemeLengthRange  = prosodyRange{0, 1.5}
+	postPhonemeLengthRange = prosodyRange{0, 1.5}
+)
+
+// Prosody adjusts an AudioQuery. A nil field leaves the value of the AudioQuery as it is.
+type Prosody struct {
+	SpeedScale        *float32 `yaml:"SpeedScale" json:"speed_scale,omitempty"`
+	PitchScale        *float32 `yaml:"PitchScale" json:"pitch_scale,omitempty"`
+	IntonationScale   *float32 `yaml:"IntonationScale" json:"intonation_scale,omitempty"`
+	VolumeScale       *float32 `yaml:"VolumeScale" json:"volume_scale,omitempty"`
+	PrePhonemeLength  *float32 `yaml:"PrePhonemeLength" json:"pre_phoneme_length,omitempty"`
+	PostPhonemeLength *float32 `yaml:"PostPhonemeLength" json:"post_phoneme_length,omitempty"`
+}
+
+// Merge returns p overridden by the non-nil fields of override.
+func (p Prosody) Merge(override Prosody) Prosody {
+	var merge = func(base, o *float32) *float32 {
+		if o != nil {
+			return o
+		}
+		return base
+	}
+
+	return Prosody{
+		SpeedScale:        merge(p.SpeedScale, override.SpeedScale),
+		PitchScale:        merge(p.PitchScale, override.PitchScale),
+		IntonationScale:   merge(p.IntonationScale, override.IntonationScale),
+		VolumeScale:       merge(p.VolumeScale, override.VolumeScale),
+		PrePhonemeLength:  merge(p.PrePhonemeLength, override.PrePhonemeLength),
+		PostPhonemeLength: merge(p.PostPhonemeLength, override.PostPhonemeLength),
+	}
+}
+
+// Validate returns an error if a field is out of its range.
+// The fields are named as in JSON.
+func (p Prosody) Validate() error {
+	var fields = []struct {
+		name  string
+		value *float32
+		r     prosodyRange
+	}{
+		{"speed_scale", p.SpeedScale, speedScaleRange},
+		{"pitch_scale", p.PitchScale, pitchScaleRange},
+		{"intonation_scale", p.IntonationScale, intonationScaleRange},
+		{"volume_scale", p.VolumeScale, volumeScaleRange},
+		{"pre_phoneme_length", p.PrePhonemeLength, prePhonemeLengthRange},
+		{"post_phoneme_length", p.PostPhonemeLength, postPhonemeLengthRange},
+	}
+
+	for _, f := range fields {
+		if f.value != nil && (*f.value < f.r.min || *f.value > f.r.max) {
+			return fmt.Errorf("%s must be between %g and %g", f.name, f.r.min, f.r.max)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether p changes nothing.
+func (p Prosody) IsZero() bool {
+	return p == Prosody{}
+}
+
+func (p Prosody) Apply(query *voicevox.VoicevoxAudioQuery) {
+	var apply = func(dst *float32, v *float32) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+
+	apply(&query.SpeedScale, p.SpeedScale)
+	apply(&query.PitchScale, p.PitchScale)
+	apply(&query.IntonationScale, p.IntonationScale)
+	apply(&query.VolumeScale, p.VolumeScale)
+	apply(&query.PrePhonemeLength, p.PrePhonemeLength)
+	apply(&query.PostPhonemeLength, p.PostPhonemeLength)
+}
+
+// applyProsody returns audioQueryJSON adjusted by prosody.
+func applyProsody(audioQueryJSON string, prosody Prosody) (string, error) {
+	if prosody.IsZero() {
+		return audioQueryJSON, nil
+	}
+
+	query, err := voicevox.ParseAudioQuery(audioQueryJSON)
+	if err != nil {
+		return "", err
+	}
+
+	prosody.Apply(query)
+
+	return query.JSON()
+}
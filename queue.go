@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityUrgent
+)
+
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "urgent":
+		return PriorityUrgent, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q", s)
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityUrgent:
+		return "urgent"
+	}
+	return "normal"
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Priority) UnmarshalText(b []byte) error {
+	var err error
+	*p, err = ParsePriority(string(b))
+	return err
+}
+
+// Message is a text waiting to be spoken.
+type Message struct {
+	ID      string         `json:"id"`
+	Text    string         `json:"text"`
+	Options MessageOptions `json:"options"`
+	// Origin is the name of the input which the message came from (e.g. "slack")
+	Origin string `json:"origin"`
+	// Meta keeps the data the origin needs to reply, such as the Slack channel and timestamp.
+	// It is persisted with the message, so that the reply can be sent even after a restart.
+	Meta      map[string]string `json:"meta,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	// Reply is set by the origin. It is nil if the origin does not need the result.
+	Reply ReplyFunc `json:"-"`
+}
+
+func NewMessage(origin, text string, options MessageOptions) *Message {
+	return &Message{
+		ID:        newMessageID(),
+		Text:      text,
+		Options:   options,
+		Origin:    origin,
+		Meta:      map[string]string{},
+		CreatedAt: time.Now(),
+	}
+}
+
+func newMessageID() string {
+	var b = make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type DropPolicy string
+
+const (
+	// DropPolicyReject refuses a new message when the queue is full.
+	DropPolicyReject DropPolicy = "reject"
+	// DropPolicyOldest discards the oldest waiting message to make room for a new one.
+	DropPolicyOldest DropPolicy = "drop-oldest"
+)
+
+var (
+	ErrQueueFull = errors.New("The queue is full, so the message was rejected.")
+	ErrDropped   = errors.New("The message was dropped from the queue to make room for newer ones.")
+	ErrCanceled  = errors.New("The message was canceled.")
+	ErrStopped   = errors.New("The message was stopped.")
+	ErrShutdown  = errors.New("The bot was shut down before the message was spoken.")
+)
+
+// MessageQueue orders messages by priority and then by arrival.
+// If QueueSetting.File is set, the queue is written to the file on every change
+// and read back by NewMessageQueue.
+type MessageQueue struct {
+	settings QueueSetting
+
+	mu sync.Mutex
+	// processing is the message returned by Pop and not yet passed to Done.
+	processing *Message
+	items      []*Message
+	notify     chan struct{}
+}
+
+type queueFile struct {
+	Processing *Message   `json:"processing,omitempty"`
+	Items      []*Message `json:"items"`
+}
+
+func NewMessageQueue(settings QueueSetting) (*MessageQueue, error) {
+	switch settings.DropPolicy {
+	case "", DropPolicyReject, DropPolicyOldest:
+	default:
+		return nil, fmt.Errorf("Unknown drop policy %q", settings.DropPolicy)
+	}
+
+	var q = &MessageQueue{
+		settings: settings,
+		notify:   make(chan struct{}, 1),
+	}
+
+	if settings.File == "" {
+		return q, nil
+	}
+
+	b, err := os.ReadFile(settings.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %v", err)
+	}
+
+	var f queueFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal: %s: %v", settings.File, err)
+	}
+
+	// the message being spoken when the program stopped is spoken again
+	if f.Processing != nil {
+		q.items = append(q.items, f.Processing)
+	}
+	for _, m := range f.Items {
+		q.insert(m)
+	}
+
+	if len(q.items) > 0 {
+		q.notify <- struct{}{}
+	}
+
+	return q, nil
+}
+
+// Push adds m to the queue.
+// When the queue is full, either m is rejected with ErrQueueFull,
+// or a waiting message is dropped and returned according to the DropPolicy.
+// An urgent message is never rejected or dropped in favor of a normal one.
+func (q *MessageQueue) Push(m *Message) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var dropped *Message
+	if q.settings.MaxLength > 0 && len(q.items) >= q.settings.MaxLength {
+		var victim = q.victim(m.Options.Priority)
+		if victim < 0 {
+			return nil, ErrQueueFull
+		}
+		dropped = q.items[victim]
+		q.items = append(q.items[:victim], q.items[victim+1:]...)
+	}
+
+	q.insert(m)
+	q.save()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return dropped, nil
+}
+
+// victim returns the index of the message to drop for a new message of priority p, or -1.
+func (q *MessageQueue) victim(p Priority) int {
+	switch q.settings.DropPolicy {
+	case DropPolicyOldest:
+		// the oldest message of the lowest priority
+		var lowest = -1
+		for i, m := range q.items {
+			if m.Options.Priority <= p && (lowest < 0 || m.Options.Priority < q.items[lowest].Options.Priority) {
+				lowest = i
+			}
+		}
+		return lowest
+	default:
+		// only a message of lower priority is dropped, and the newest one first
+		for i := len(q.items) - 1; i >= 0; i-- {
+			if q.items[i].Options.Priority < p {
+				return i
+			}
+		}
+		return -1
+	}
+}
+
+// insert puts m after every message of the same or higher priority.
+func (q *MessageQueue) insert(m *Message) {
+	var i = len(q.items)
+	for i > 0 && q.items[i-1].Options.Priority < m.Options.Priority {
+		i--
+	}
+	q.items = append(q.items, nil)
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = m
+}
+
+// Pop waits for a message and takes it off the queue.
+// The message is kept on disk until Done is called.
+// It returns nil if ctx is done while the queue is empty.
+func (q *MessageQueue) Pop(ctx context.Context) *Message {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			var m = q.items[0]
+			q.items = q.items[1:]
+			q.processing = m
+			q.save()
+
+			if len(q.items) > 0 {
+				select {
+				case q.notify <- struct{}{}:
+				default:
+				}
+			}
+			q.mu.Unlock()
+			return m
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Done tells the queue that m, returned by Pop, has been processed.
+func (q *MessageQueue) Done(m *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.processing == m {
+		q.processing = nil
+		q.save()
+	}
+}
+
+// Remove takes the waiting message of id off the queue and returns it, or nil if it is not waiting.
+func (q *MessageQueue) Remove(id string) *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, m := range q.items {
+		if m.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			q.save()
+			return m
+		}
+	}
+	return nil
+}
+
+// Processing returns the message returned by Pop and not yet passed to Done, or nil.
+func (q *MessageQueue) Processing() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.processing
+}
+
+// Peek returns the message which Pop returns next, or nil if the queue is empty.
+func (q *MessageQueue) Peek() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil
+	}
+	return q.items[0]
+}
+
+// Messages returns the message being processed and the waiting messages in order.
+func (q *MessageQueue) Messages() []*Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var messages []*Message
+	if q.processing != nil {
+		messages = append(messages, q.processing)
+	}
+	return append(messages, q.items...)
+}
+
+// Len returns the number of the waiting messages.
+func (q *MessageQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+func (q *MessageQueue) save() {
+	if q.settings.File == "" {
+		return
+	}
+
+	b, err := json.Marshal(queueFile{Processing: q.processing, Items: q.items})
+	if err != nil {
+		slog.Error("Failed to marshal the queue", "error", err)
+		return
+	}
+
+	// write to a temporary file and rename it, so that the file is never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(q.settings.File), filepath.Base(q.settings.File)+".*")
+	if err != nil {
+		slog.Error("Failed to save the queue", "error", err)
+		return
+	}
+	_, err = tmp.Write(b)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Error("Failed to save the queue", "error", err)
+		return
+	}
+
+	err = os.Rename(tmp.Name(), q.settings.File)
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Error("Failed to save the queue", "error", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Package schedule decides whether an announcement can be played now.
+// A Policy has the windows of the week in which announcements are played,
+// and an Action for the messages which come outside of them.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Action is what is done with a message.
+type Action string
+
+const (
+	// Play plays the message as usual.
+	Play Action = "play"
+	// Hold keeps the message until the next window opens.
+	Hold Action = "hold"
+	// Drop throws the message away.
+	Drop Action = "drop"
+	// Reduce plays the message at Options.ReducedVolume.
+	Reduce Action = "reduce"
+	// UrgentOnly plays urgent messages and throws the others away.
+	UrgentOnly Action = "urgent-only"
+)
+
+// defaultReducedVolume is the volume of Reduce when Options.ReducedVolume is not set.
+const defaultReducedVolume = 0.2
+
+// WindowOptions is a time window on some days of the week.
+type WindowOptions struct {
+	// Days are the weekdays such as "mon" and "fri", or ranges such as "mon-fri". Every day is in the window if it is empty.
+	Days []string
+	// Start and End are times such as "09:00" and "18:30". End may be "24:00".
+	// If End is not after Start, the window ends on the next day.
+	Start string
+	End   string
+}
+
+type Options struct {
+	// TimeZone is an IANA time zone such as "Asia/Tokyo". The local time zone is used if it is empty.
+	TimeZone string
+	// Windows are when messages are played. Every time is in a window if it is empty.
+	Windows []WindowOptions
+	// Outside is the action outside of the windows. The default is Hold.
+	Outside Action
+	// ReducedVolume is the volume of Reduce between 0 and 1.
+	ReducedVolume float32
+	// Clock is SystemClock if it is nil.
+	Clock Clock
+}
+
+// Decision is what to do with a message.
+type Decision struct {
+	Action Action
+	// Outside is the action of the policy outside of the windows, or empty in a window.
+	// It is UrgentOnly when a message is dropped for not being urgent.
+	Outside Action
+	// Until is when the next window opens. It is set with Hold.
+	Until time.Time
+	// Volume is the volume to play at. It is set with Reduce.
+	Volume float32
+}
+
+// Policy decides the actions of messages.
+type Policy struct {
+	windows  []window
+	location *time.Location
+	outside  Action
+	volume   float32
+	clock    Clock
+}
+
+// window is [start, end) in minutes from midnight on days.
+type window struct {
+	days       [7]bool
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// New returns the policy of options.
+func New(options Options) (*Policy, error) {
+	var p = &Policy{
+		location: time.Local,
+		outside:  options.Outside,
+		volume:   options.ReducedVolume,
+		clock:    options.Clock,
+	}
+
+	if options.TimeZone != "" {
+		location, err := time.LoadLocation(options.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("TimeZone: %v", err)
+		}
+		p.location = location
+	}
+
+	switch p.outside {
+	case "":
+		p.outside = Hold
+	case Hold, Drop, Reduce, UrgentOnly:
+	default:
+		return nil, fmt.Errorf("Unknown action %q", p.outside)
+	}
+
+	if p.volume == 0 {
+		p.volume = defaultReducedVolume
+	}
+	if p.volume < 0 || p.volume > 1 {
+		return nil, fmt.Errorf("ReducedVolume must be between 0 and 1")
+	}
+
+	if p.clock == nil {
+		p.clock = SystemClock{}
+	}
+
+	for i, options := range options.Windows {
+		w, err := parseWindow(options)
+		if err != nil {
+			return nil, fmt.Errorf("Windows[%d]: %v", i, err)
+		}
+		p.windows = append(p.windows, w)
+	}
+
+	return p, nil
+}
+
+func parseWindow(options WindowOptions) (window, error) {
+	var w window
+	var err error
+
+	w.start, err = parseClock(options.Start)
+	if err != nil {
+		return w, fmt.Errorf("Start: %v", err)
+	}
+	w.end, err = parseClock(options.End)
+	if err != nil {
+		return w, fmt.Errorf("End: %v", err)
+	}
+
+	if len(options.Days) == 0 {
+		for d := range w.days {
+			w.days[d] = true
+		}
+		return w, nil
+	}
+
+	for _, days := range options.Days {
+		first, last, isRange := strings.Cut(strings.ToLower(days), "-")
+		if !isRange {
+			last = first
+		}
+
+		from, ok := weekdays[first]
+		if !ok {
+			return w, fmt.Errorf("unknown day %q", first)
+		}
+		to, ok := weekdays[last]
+		if !ok {
+			return w, fmt.Errorf("unknown day %q", last)
+		}
+
+		// a range such as fri-mon goes over the weekend
+		for d := from; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// parseClock returns the minutes from midnight of s such as "09:00".
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Now returns the time of the clock in the time zone of the policy.
+func (p *Policy) Now() time.Time {
+	return p.clock.Now().In(p.location)
+}
+
+// Decide returns what to do with a message now.
+func (p *Policy) Decide(urgent bool) Decision {
+	var now = p.Now()
+	if p.Open(now) {
+		return Decision{Action: Play}
+	}
+
+	var d = Decision{Action: p.outside, Outside: p.outside}
+	switch p.outside {
+	case Hold:
+		d.Until = p.NextOpen(now)
+	case Reduce:
+		d.Volume = p.volume
+	case UrgentOnly:
+		d.Action = Drop
+		if urgent {
+			d.Action = Play
+		}
+	}
+	return d
+}
+
+// Open returns whether t is in a window.
+func (p *Policy) Open(t time.Time) bool {
+	if len(p.windows) == 0 {
+		return true
+	}
+
+	t = t.In(p.location)
+	var minute = t.Hour()*60 + t.Minute()
+	var today, yesterday = t.Weekday(), (t.Weekday() + 6) % 7
+
+	for _, w := range p.windows {
+		if w.start < w.end {
+			if w.days[today] && w.start <= minute && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// the window started today or yesterday and ends on the next day
+		if w.days[today] && minute >= w.start || w.days[yesterday] && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the next window opens after t.
+// It returns t if t is in a window, and the zero time if there is no window.
+func (p *Policy) NextOpen(t time.Time) time.Time {
+	if p.Open(t) {
+		return t
+	}
+
+	t = t.In(p.location)
+	var next time.Time
+	for day := 0; day <= 7; day++ {
+		var date = t.AddDate(0, 0, day)
+		for _, w := range p.windows {
+			if !w.days[date.Weekday()] {
+				continue
+			}
+			var start = time.Date(date.Year(), date.Month(), date.Day(), w.start/60, w.start%60, 0, 0, p.location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// Wait waits until t or until ctx is done.
+func (p *Policy) Wait(ctx context.Context, t time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.clock.After(t.Sub(p.clock.Now())):
+		return nil
+	}
+}
+
+// String tells the sender what is done with the message.
+func (d Decision) String() string {
+	switch {
+	case d.Outside == "":
+		return "The message is played."
+	case d.Action == Hold:
+		return fmt.Sprintf("It is outside the announcement hours, so the message is held until %s.", d.Until.Format("Mon 15:04"))
+	case d.Action == Reduce:
+		return fmt.Sprintf("It is outside the announcement hours, so the message is played at volume %.2f.", d.Volume)
+	case d.Action == Play:
+		return "It is outside the announcement hours, but the message is played since it is urgent."
+	case d.Outside == UrgentOnly:
+		return "It is outside the announcement hours, and only urgent messages are played, so the message was dropped."
+	}
+	return "It is outside the announcement hours, so the message was dropped."
+}
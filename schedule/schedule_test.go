@@ -0,0 +1,280 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s: %v", name, err)
+	}
+	return location
+}
+
+func mustNew(t *testing.T, options Options) *Policy {
+	t.Helper()
+
+	p, err := New(options)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestOpen(t *testing.T) {
+	var tokyo = mustLoadLocation(t, "Asia/Tokyo")
+	var at = func(day, hour, minute int) time.Time {
+		// 2024-05-06 is a Monday
+		return time.Date(2024, 5, 6+day, hour, minute, 0, 0, tokyo)
+	}
+
+	var tests = []struct {
+		name    string
+		windows []WindowOptions
+		t       time.Time
+		want    bool
+	}{
+		{"no windows", nil, at(0, 3, 0), true},
+		{"in a day window", []WindowOptions{{Start: "09:00", End: "18:00"}}, at(0, 9, 0), true},
+		{"end of a day window", []WindowOptions{{Start: "09:00", End: "18:00"}}, at(0, 18, 0), false},
+
+		{"overnight before midnight", []WindowOptions{{Start: "22:00", End: "07:00"}}, at(0, 23, 30), true},
+		{"overnight after midnight", []WindowOptions{{Start: "22:00", End: "07:00"}}, at(1, 6, 59), true},
+		{"overnight end", []WindowOptions{{Start: "22:00", End: "07:00"}}, at(1, 7, 0), false},
+		{"overnight daytime", []WindowOptions{{Start: "22:00", End: "07:00"}}, at(1, 12, 0), false},
+		{"overnight from a listed day", []WindowOptions{{Days: []string{"fri"}, Start: "22:00", End: "07:00"}}, at(5, 6, 0), true},
+		{"overnight on the day after", []WindowOptions{{Days: []string{"fri"}, Start: "22:00", End: "07:00"}}, at(5, 22, 0), false},
+
+		{"fri-mon on saturday", []WindowOptions{{Days: []string{"fri-mon"}, Start: "09:00", End: "17:00"}}, at(5, 10, 0), true},
+		{"fri-mon on monday", []WindowOptions{{Days: []string{"fri-mon"}, Start: "09:00", End: "17:00"}}, at(0, 10, 0), true},
+		{"fri-mon on tuesday", []WindowOptions{{Days: []string{"fri-mon"}, Start: "09:00", End: "17:00"}}, at(1, 10, 0), false},
+		{"upper case days", []WindowOptions{{Days: []string{"Sat", "SUN"}, Start: "00:00", End: "24:00"}}, at(6, 10, 0), true},
+
+		{"before 24:00", []WindowOptions{{Days: []string{"sat"}, Start: "18:00", End: "24:00"}}, at(5, 23, 59), true},
+		{"at 24:00", []WindowOptions{{Days: []string{"sat"}, Start: "18:00", End: "24:00"}}, at(6, 0, 0), false},
+		{"whole day", []WindowOptions{{Start: "00:00", End: "24:00"}}, at(3, 0, 0), true},
+
+		{"second window", []WindowOptions{{Start: "07:00", End: "08:00"}, {Start: "12:00", End: "13:00"}}, at(0, 12, 30), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = mustNew(t, Options{TimeZone: "Asia/Tokyo", Windows: tt.windows})
+			if got := p.Open(tt.t); got != tt.want {
+				t.Errorf("Open(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	var tokyo = mustLoadLocation(t, "Asia/Tokyo")
+	var at = func(day, hour, minute int) time.Time {
+		// 2024-05-06 is a Monday
+		return time.Date(2024, 5, 6+day, hour, minute, 0, 0, tokyo)
+	}
+
+	var tests = []struct {
+		name    string
+		windows []WindowOptions
+		t, want time.Time
+	}{
+		{"in a window", []WindowOptions{{Start: "09:00", End: "18:00"}}, at(0, 10, 0), at(0, 10, 0)},
+		{"later today", []WindowOptions{{Start: "09:00", End: "18:00"}}, at(0, 8, 0), at(0, 9, 0)},
+		{"tomorrow", []WindowOptions{{Start: "09:00", End: "18:00"}}, at(0, 18, 0), at(1, 9, 0)},
+		{"overnight", []WindowOptions{{Start: "22:00", End: "07:00"}}, at(0, 7, 0), at(0, 22, 0)},
+		{"fri-mon from tuesday", []WindowOptions{{Days: []string{"fri-mon"}, Start: "09:00", End: "17:00"}}, at(1, 12, 0), at(4, 9, 0)},
+		{"next week", []WindowOptions{{Days: []string{"mon"}, Start: "09:00", End: "10:00"}}, at(0, 10, 0), at(7, 9, 0)},
+		{"after 24:00", []WindowOptions{{Days: []string{"sat"}, Start: "18:00", End: "24:00"}}, at(6, 0, 0), at(12, 18, 0)},
+		{"earliest window", []WindowOptions{{Start: "12:00", End: "13:00"}, {Start: "07:00", End: "08:00"}}, at(0, 20, 0), at(1, 7, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = mustNew(t, Options{TimeZone: "Asia/Tokyo", Windows: tt.windows})
+			if got := p.NextOpen(tt.t); !got.Equal(tt.want) {
+				t.Errorf("NextOpen(%s) = %s, want %s", tt.t.Format("Mon 15:04"), got.Format("Mon Jan 2 15:04"), tt.want.Format("Mon Jan 2 15:04"))
+			}
+		})
+	}
+}
+
+func TestDaylightSaving(t *testing.T) {
+	mustLoadLocation(t, "Asia/Tokyo")
+	mustLoadLocation(t, "America/New_York")
+
+	var windows = []WindowOptions{{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"}}
+
+	// New York moves from EST (-5) to EDT (-4) on Sunday 2024-03-10, while Tokyo stays at +9.
+	var tests = []struct {
+		timeZone string
+		// open is whether it is open on 2024-03-04 and 2024-03-11 at 13:30 UTC.
+		open [2]bool
+		// next is when it opens after Friday 2024-03-08 23:00 UTC.
+		next time.Time
+	}{
+		{"Asia/Tokyo", [2]bool{false, false}, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"America/New_York", [2]bool{false, true}, time.Date(2024, 3, 11, 13, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timeZone, func(t *testing.T) {
+			var clock = NewFakeClock(time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC))
+			var p = mustNew(t, Options{TimeZone: tt.timeZone, Windows: windows, Clock: clock})
+
+			for i, u := range []time.Time{
+				time.Date(2024, 3, 4, 13, 30, 0, 0, time.UTC),
+				time.Date(2024, 3, 11, 13, 30, 0, 0, time.UTC),
+			} {
+				if got := p.Open(u); got != tt.open[i] {
+					t.Errorf("Open(%s) = %v, want %v", u.Format(time.RFC3339), got, tt.open[i])
+				}
+			}
+
+			var d = p.Decide(false)
+			if d.Action != Hold || !d.Until.Equal(tt.next) {
+				t.Errorf("Decide = %s until %s, want hold until %s", d.Action, d.Until.UTC().Format(time.RFC3339), tt.next.Format(time.RFC3339))
+			}
+			if d.Until.Location().String() != tt.timeZone {
+				t.Errorf("Until is in %s, want %s", d.Until.Location(), tt.timeZone)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	var tokyo = mustLoadLocation(t, "Asia/Tokyo")
+	var windows = []WindowOptions{{Start: "09:00", End: "18:00"}}
+	var inside = time.Date(2024, 5, 6, 12, 0, 0, 0, tokyo)
+	var outside = time.Date(2024, 5, 6, 20, 0, 0, 0, tokyo)
+
+	var tests = []struct {
+		name    string
+		outside Action
+		volume  float32
+		now     time.Time
+		urgent  bool
+		want    Decision
+	}{
+		{"play in a window", Drop, 0, inside, false, Decision{Action: Play}},
+		{"hold", Hold, 0, outside, false, Decision{Action: Hold, Outside: Hold, Until: time.Date(2024, 5, 7, 9, 0, 0, 0, tokyo)}},
+		{"hold by default", "", 0, outside, false, Decision{Action: Hold, Outside: Hold, Until: time.Date(2024, 5, 7, 9, 0, 0, 0, tokyo)}},
+		{"drop", Drop, 0, outside, true, Decision{Action: Drop, Outside: Drop}},
+		{"reduce", Reduce, 0.5, outside, false, Decision{Action: Reduce, Outside: Reduce, Volume: 0.5}},
+		{"reduce to the default volume", Reduce, 0, outside, false, Decision{Action: Reduce, Outside: Reduce, Volume: defaultReducedVolume}},
+		{"urgent only with an urgent message", UrgentOnly, 0, outside, true, Decision{Action: Play, Outside: UrgentOnly}},
+		{"urgent only with a usual message", UrgentOnly, 0, outside, false, Decision{Action: Drop, Outside: UrgentOnly}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = mustNew(t, Options{
+				TimeZone:      "Asia/Tokyo",
+				Windows:       windows,
+				Outside:       tt.outside,
+				ReducedVolume: tt.volume,
+				Clock:         NewFakeClock(tt.now),
+			})
+
+			var got = p.Decide(tt.urgent)
+			if got.Action != tt.want.Action || got.Outside != tt.want.Outside || !got.Until.Equal(tt.want.Until) || got.Volume != tt.want.Volume {
+				t.Errorf("Decide(%v) = %+v, want %+v", tt.urgent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, options := range []Options{
+		{TimeZone: "Nowhere/City"},
+		{Outside: Play},
+		{Outside: "later"},
+		{ReducedVolume: 1.5},
+		{Windows: []WindowOptions{{Start: "9:00pm", End: "10:00"}}},
+		{Windows: []WindowOptions{{Start: "09:00", End: "24:30"}}},
+		{Windows: []WindowOptions{{Days: []string{"mon-fry"}, Start: "09:00", End: "10:00"}}},
+	} {
+		_, err := New(options)
+		if err == nil {
+			t.Errorf("New(%+v) succeeded", options)
+		}
+	}
+}
+
+// waitForAfter waits until clock has n channels of After which have not fired.
+func waitForAfter(t *testing.T, clock *FakeClock, n int) {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		clock.mu.Lock()
+		var waiting = len(clock.waiters)
+		clock.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d channels of After are not waiting", n)
+}
+
+func TestWait(t *testing.T) {
+	var now = time.Date(2024, 5, 6, 20, 0, 0, 0, time.UTC)
+	var clock = NewFakeClock(now)
+	var p = mustNew(t, Options{Clock: clock})
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- p.Wait(context.Background(), now.Add(time.Hour))
+	}()
+	waitForAfter(t, clock, 1)
+
+	clock.Advance(59 * time.Minute)
+	select {
+	case err := <-done:
+		t.Fatalf("Wait returned %v before the time", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return when the clock reached the time")
+	}
+
+	// a time which has passed
+	if err := p.Wait(context.Background(), now); err != nil {
+		t.Errorf("Wait for a past time: %v", err)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	var now = time.Date(2024, 5, 6, 20, 0, 0, 0, time.UTC)
+	var clock = NewFakeClock(now)
+	var p = mustNew(t, Options{Clock: clock})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan error, 1)
+	go func() {
+		done <- p.Wait(ctx, now.Add(time.Hour))
+	}()
+	waitForAfter(t, clock, 1)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Wait = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return when the context was canceled")
+	}
+}
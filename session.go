@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vishen/go-chromecast/application"
+)
+
+type PlayMode string
+
+const (
+	// PlayModeInterrupt stops what the device is playing (default)
+	PlayModeInterrupt PlayMode = "interrupt"
+	// PlayModeWait waits until the device stops playing
+	PlayModeWait PlayMode = "wait"
+	// PlayModeDuck resumes what the device was playing after the message
+	PlayModeDuck PlayMode = "duck"
+)
+
+const (
+	defaultMediaReceiverAppID = "CC1AD845"
+	backdropAppID             = "E8C28D3C"
+
+	defaultWaitTimeout = 5 * time.Minute
+	idlePollInterval   = 2 * time.Second
+	// relaunchWait is the time given to an app to start before LoadApp is released
+	relaunchWait = 5 * time.Second
+)
+
+// mediaSession is what a device was doing before a message.
+type mediaSession struct {
+	AppID       string
+	ContentID   string
+	ContentType string
+	Position    float32
+	Playing     bool
+	// HasVolume is false if the device did not report its volume, which is then left as it is
+	HasVolume bool
+	Volume    float32
+	Muted     bool
+}
+
+func captureSession(app *application.Application) mediaSession {
+	var session mediaSession
+
+	castApp, media, volume := app.Status()
+	if volume != nil {
+		session.HasVolume = true
+		session.Volume = volume.Level
+		session.Muted = volume.Muted
+	}
+
+	if castApp == nil || castApp.IsIdleScreen || castApp.AppId == backdropAppID {
+		return session
+	}
+	session.AppID = castApp.AppId
+
+	if media != nil {
+		session.ContentID = media.Media.ContentId
+		session.ContentType = media.Media.ContentType
+		session.Position = media.CurrentTime
+		session.Playing = media.PlayerState == "PLAYING" || media.PlayerState == "BUFFERING"
+	}
+
+	return session
+}
+
+// restore sets the volume back, and if resume is true, starts the media played before.
+// Media of the Default Media Receiver is loaded again from the position,
+// and the other apps are launched again with their content.
+func (s mediaSession) restore(app *application.Application, resume bool) error {
+	var errs []string
+
+	if s.HasVolume {
+		err := app.SetVolume(s.Volume)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("SetVolume: %v", err))
+		}
+	}
+	if s.Muted {
+		err := app.SetMuted(true)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("SetMuted: %v", err))
+		}
+	}
+
+	if resume && s.Playing {
+		err := s.resume(app)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (s mediaSession) resume(app *application.Application) error {
+	if s.AppID == defaultMediaReceiverAppID {
+		if !strings.HasPrefix(s.ContentID, "http://") && !strings.HasPrefix(s.ContentID, "https://") {
+			return fmt.Errorf("unable to resume %q", s.ContentID)
+		}
+
+		err := app.Load(s.ContentID, int(s.Position), s.ContentType, false, true, false)
+		if err != nil {
+			return fmt.Errorf("Load: %v", err)
+		}
+		return nil
+	}
+
+	// LoadApp waits until the media finishes, so it is released after the app has started
+	var done = make(chan error, 1)
+	go func() {
+		done <- app.LoadApp(s.AppID, s.ContentID)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("LoadApp: %v", err)
+		}
+	case <-time.After(relaunchWait):
+		// the connection is used for the next message, so LoadApp must have returned
+		app.MediaFinished()
+		<-done
+	}
+
+	return nil
+}
+
+// waitForIdle waits until the device stops playing, timeout passes or ctx is done.
+func waitForIdle(ctx context.Context, app *application.Application, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+
+	for {
+		if !captureSession(app).Playing {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("The device kept playing for %s, so the message was not played.", timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrStopped
+		case <-time.After(idlePollInterval):
+		}
+
+		err := app.Update()
+		if err != nil {
+			return fmt.Errorf("Update: %v", err)
+		}
+	}
+}
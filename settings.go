@@ -4,40 +4,373 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/kmc-jp/GoogleHomeNotifier/cache"
+	"github.com/kmc-jp/GoogleHomeNotifier/normalize"
+	"github.com/kmc-jp/GoogleHomeNotifier/schedule"
 	"github.com/pkg/errors"
 )
 
 type Setting struct {
-	Voicevox   VoicevoxSetting   `yaml:"Voicevox"`
-	GoogleHome GoogleHomeSetting `yaml:"GoogleHome"`
-	Slack      SlackSetting      `yaml:"Slack"`
+	TTS      TTSSetting      `yaml:"TTS"`
+	Voicevox VoicevoxSetting `yaml:"Voicevox"`
+	// GoogleHome is a single device. It is used when GoogleHomes is empty.
+	GoogleHome       GoogleHomeSetting   `yaml:"GoogleHome"`
+	GoogleHomes      []GoogleHomeSetting `yaml:"GoogleHomes"`
+	GoogleHomeGroups map[string][]string `yaml:"GoogleHomeGroups"`
+	DefaultTarget    string              `yaml:"DefaultTarget"`
+	Slack            SlackSetting        `yaml:"Slack"`
+	Queue            QueueSetting        `yaml:"Queue"`
+	HTTP             HTTPSetting         `yaml:"HTTP"`
+	Normalize        NormalizeSetting    `yaml:"Normalize"`
+	Chime            ChimeSetting        `yaml:"Chime"`
+	MediaServer      MediaServerSetting  `yaml:"MediaServer"`
+	Encoding         EncodingSetting     `yaml:"Encoding"`
+	Schedule         ScheduleSetting     `yaml:"Schedule"`
+	Cache            CacheSetting        `yaml:"Cache"`
+	Shutdown         ShutdownSetting     `yaml:"Shutdown"`
+	Log              LogSetting          `yaml:"Log"`
+	History          HistorySetting      `yaml:"History"`
+	// Inputs are the names of the input sources to start: slack, http and stdin
+	Inputs []string `yaml:"Inputs"`
+}
+
+type TTSSetting struct {
+	// Engine is voicevox (default), voicevox-engine or command
+	Engine         string                `yaml:"Engine"`
+	VoicevoxEngine VoicevoxEngineSetting `yaml:"VoicevoxEngine"`
+	Command        CommandTTSSetting     `yaml:"Command"`
+	// Workers is the number of sentences synthesized at once.
+	// By default, it is the number of CPUs divided by Voicevox.CpuNumThreads, or 1.
+	Workers int `yaml:"Workers"`
+}
+
+type VoicevoxEngineSetting struct {
+	// URL is the base URL of VOICEVOX Engine (e.g. http://localhost:50021)
+	URL string `yaml:"URL"`
+}
+
+type CommandTTSSetting struct {
+	// Command is the command line to run. See CommandEngine for the placeholders.
+	Command []string `yaml:"Command"`
+	// Voice is the default value of "{voice}"
+	Voice string `yaml:"Voice"`
+	// Timeout is how long the command can run in seconds. The default is 30.
+	Timeout float32 `yaml:"Timeout"`
+}
+
+func (s CommandTTSSetting) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return defaultCommandTimeout
+	}
+	return time.Duration(s.Timeout * float32(time.Second))
 }
 
 type VoicevoxSetting struct {
 	SpeakerID        uint32 `yaml:"SpeakerID"`
 	OpenJtalkDictDir string `yaml:"OpenJtalkDictDir"`
+	// CpuNumThreads is the threads VOICEVOX Core uses for a synthesis. 0 lets VOICEVOX decide.
+	CpuNumThreads uint16 `yaml:"CpuNumThreads"`
+	// UserDictFile is the JSON file of the user dictionary. Without it, the words are not saved.
+	// VOICEVOX Core reads the words only by their pronunciations, and ignores the accent types, word types and priorities.
+	UserDictFile string `yaml:"UserDictFile"`
+	// Prosody is the default prosody. Messages can override it.
+	Prosody Prosody `yaml:"Prosody"`
 }
 
 type GoogleHomeSetting struct {
-	DeviceName  string  `yaml:"DeviceName"`
-	Device      string  `yaml:"Device"`
-	Iface       string  `yaml:"Iface"`
-	ForceDetach bool    `yaml:"ForceDetach"`
-	Detach      bool    `yaml:"Detach"`
-	Addr        string  `yaml:"Addr"`
-	Port        int     `yaml:"Port"`
-	UUID        string  `yaml:"UUID"`
-	Volume      float32 `yaml:"Volume"`
-	MaxDuration float32 `yaml:"MaxDuration"`
+	Name             string  `yaml:"Name"`
+	DeviceName       string  `yaml:"DeviceName"`
+	Device           string  `yaml:"Device"`
+	Iface            string  `yaml:"Iface"`
+	Addr             string  `yaml:"Addr"`
+	Port             int     `yaml:"Port"`
+	UUID             string  `yaml:"UUID"`
+	Volume           float32 `yaml:"Volume"`
+	MaxDuration      float32 `yaml:"MaxDuration"`
+	DiscoveryTimeout float32 `yaml:"DiscoveryTimeout"`
+	// Mode is interrupt (default), wait or duck. See PlayMode.
+	Mode PlayMode `yaml:"Mode"`
+	// WaitTimeout is how long the wait mode waits in seconds. The default is 300.
+	WaitTimeout float32 `yaml:"WaitTimeout"`
+	// Detach and ForceDetach are no longer used. The bot always detaches from the media,
+	// since it waits for the end by itself to stop the message and to restore the device.
+	Detach      bool `yaml:"Detach"`
+	ForceDetach bool `yaml:"ForceDetach"`
+}
+
+// maxDuration returns MaxDuration, or 0 if it is not limited.
+func (s GoogleHomeSetting) maxDuration() time.Duration {
+	return time.Duration(s.MaxDuration * float32(time.Second))
+}
+
+func (s GoogleHomeSetting) playMode() PlayMode {
+	if s.Mode == "" {
+		return PlayModeInterrupt
+	}
+	return s.Mode
+}
+
+func (s GoogleHomeSetting) waitTimeout() time.Duration {
+	if s.WaitTimeout <= 0 {
+		return defaultWaitTimeout
+	}
+	return time.Duration(s.WaitTimeout * float32(time.Second))
+}
+
+// TTSWorkers returns TTS.Workers, or the number of synthesis which fit in the CPUs.
+func (s *Setting) TTSWorkers() int {
+	if s.TTS.Workers > 0 {
+		return s.TTS.Workers
+	}
+	if s.Voicevox.CpuNumThreads > 0 {
+		return max(1, runtime.NumCPU()/int(s.Voicevox.CpuNumThreads))
+	}
+	return 1
+}
+
+// GoogleHomeSettings returns the settings of every device.
+func (s *Setting) GoogleHomeSettings() []GoogleHomeSetting {
+	if len(s.GoogleHomes) > 0 {
+		return s.GoogleHomes
+	}
+
+	var single = s.GoogleHome
+	if single.Name == "" {
+		single.Name = defaultDeviceName
+	}
+	return []GoogleHomeSetting{single}
 }
 
 type SlackSetting struct {
 	Token         string `yaml:"Token"`
 	AppLevelToken string `yaml:"AppLevelToken"`
 	Icon          string `yaml:"Icon"`
+	// Access limits who can use the bot and when
+	Access SlackAccessSetting `yaml:"Access"`
+}
+
+// SlackAccessSetting limits who can use the bot from Slack. Everything is allowed by default.
+type SlackAccessSetting struct {
+	// Channels are the IDs of the channels the bot answers in
+	Channels []string `yaml:"Channels"`
+	// Users and UserGroups are the IDs of the users and the user groups who can use the bot.
+	// Everyone can if both are empty.
+	Users      []string `yaml:"Users"`
+	UserGroups []string `yaml:"UserGroups"`
+	// DeniedUsers and DeniedUserGroups cannot use the bot even if they are allowed above
+	DeniedUsers      []string `yaml:"DeniedUsers"`
+	DeniedUserGroups []string `yaml:"DeniedUserGroups"`
+	// QuietHours is when no message is taken
+	QuietHours QuietHoursSetting `yaml:"QuietHours"`
+	// RateLimit limits the messages of each user
+	RateLimit RateLimitSetting `yaml:"RateLimit"`
+}
+
+type QuietHoursSetting struct {
+	// Start and End are times such as "22:00" and "07:00" in Schedule.TimeZone. End may be on the next day.
+	Start string `yaml:"Start"`
+	End   string `yaml:"End"`
+}
+
+// Policy returns the policy whose window is the time outside the quiet hours, or nil if there are none.
+// The times are in timeZone.
+func (s QuietHoursSetting) Policy(timeZone string) (*schedule.Policy, error) {
+	if s.Start == "" && s.End == "" {
+		return nil, nil
+	}
+
+	policy, err := schedule.New(schedule.Options{
+		TimeZone: timeZone,
+		Windows:  []schedule.WindowOptions{{Start: s.End, End: s.Start}},
+		Outside:  schedule.Drop,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q-%q: %v", s.Start, s.End, err)
+	}
+	return policy, nil
+}
+
+type RateLimitSetting struct {
+	// Messages is how many messages a user can send in a row. 0 means no limit.
+	Messages int `yaml:"Messages"`
+	// Interval is the seconds in which Messages are allowed again
+	Interval float32 `yaml:"Interval"`
+}
+
+// CacheSetting limits the synthesized sounds kept for the same texts. See cache.Options.
+type CacheSetting struct {
+	// Memory is the megabytes kept in memory. The default is 32, and a negative value disables it.
+	Memory float32 `yaml:"Memory"`
+	// Dir is the directory the sounds are saved to. They are not saved if it is empty.
+	Dir string `yaml:"Dir"`
+	// Disk is the megabytes kept in Dir. 0 means unlimited.
+	Disk float32 `yaml:"Disk"`
+}
+
+func (s CacheSetting) Options() cache.Options {
+	const megabyte = 1 << 20
+
+	var memory = s.Memory
+	if memory == 0 {
+		memory = defaultCacheMemory
+	}
+
+	var options = cache.Options{
+		Dir:     s.Dir,
+		MaxDisk: int64(s.Disk * megabyte),
+	}
+	if memory > 0 {
+		options.MaxMemory = int64(memory * megabyte)
+	}
+	return options
+}
+
+// ShutdownSetting decides what happens to the messages on SIGINT or SIGTERM.
+type ShutdownSetting struct {
+	// Drain is the seconds the waiting messages are still spoken for.
+	// With 0, the message being spoken is stopped at once.
+	Drain float32 `yaml:"Drain"`
+}
+
+func (s ShutdownSetting) drain() time.Duration {
+	return time.Duration(s.Drain * float32(time.Second))
+}
+
+// LogSetting chooses the logs written to the standard error.
+type LogSetting struct {
+	// Level is debug, info (default), warn or error
+	Level string `yaml:"Level"`
+	// Format is text (default) or json
+	Format string `yaml:"Format"`
+}
+
+// HistorySetting keeps the messages which were spoken. See History.
+type HistorySetting struct {
+	// File is the JSON Lines file the history is saved to. Without it, the history is forgotten on restart.
+	File string `yaml:"File"`
+	// MaxEntries is the number of messages kept. The default is 1000.
+	MaxEntries int `yaml:"MaxEntries"`
+	// MaxDays is how many days messages are kept. 0 means no limit.
+	MaxDays float32 `yaml:"MaxDays"`
+}
+
+type QueueSetting struct {
+	MaxLength  int        `yaml:"MaxLength"`
+	DropPolicy DropPolicy `yaml:"DropPolicy"`
+	File       string     `yaml:"File"`
+}
+
+type HTTPSetting struct {
+	// Listen is the address of the HTTP API (e.g. ":8080"). The API is disabled if it is empty.
+	Listen string `yaml:"Listen"`
+	// Token is the bearer token clients have to send
+	Token string `yaml:"Token"`
+}
+
+// MediaServerSetting configures the HTTP server the devices load the sounds from.
+type MediaServerSetting struct {
+	// Listen is the address to listen on (e.g. ":8081"). A free port is chosen if it is empty.
+	Listen string `yaml:"Listen"`
+	// URL is the base URL the devices connect to (e.g. http://192.168.0.2:8081).
+	// If it is empty, the address of the interface routed to each device and the listened port are used.
+	URL string `yaml:"URL"`
+}
+
+// EncodingSetting chooses the format of the sounds sent to the devices. See Encoder.
+type EncodingSetting struct {
+	// Format is wav (default), mp3 or command
+	Format string `yaml:"Format"`
+	// Bitrate is in kbps. The default is 64.
+	// For mp3, the bitrates depend on the sample rate of the sounds (see audio.MP3Bitrates):
+	// 8-64 in steps of 8 and 80-160 in steps of 16 for 16-24 kHz such as VOICEVOX, which is checked at startup,
+	// only up to 64 for 8-12 kHz, and from 32 up to 320 for 32-48 kHz.
+	// A sound whose sample rate does not have the bitrate is sent as WAV.
+	Bitrate int `yaml:"Bitrate"`
+	// MIMEType is sent to the devices. It is required for the command format.
+	MIMEType string `yaml:"MIMEType"`
+	// Command is the command line of the command format
+	Command []string `yaml:"Command"`
+}
+
+// ChimeSetting chooses the WAV files played before the speech by priority.
+type ChimeSetting struct {
+	Normal string `yaml:"Normal"`
+	Urgent string `yaml:"Urgent"`
+	// Gap is the silence between the chime and the speech in seconds
+	Gap float32 `yaml:"Gap"`
+}
+
+// NormalizeSetting configures how texts are rewritten before synthesis. See normalize.Options.
+type NormalizeSetting struct {
+	// Rules are the rules to apply in order. By default, every rule is applied.
+	Rules []string `yaml:"Rules"`
+	// URL is link (default), domain or keep
+	URL           string                `yaml:"URL"`
+	LinkWord      string                `yaml:"LinkWord"`
+	CodeBlock     string                `yaml:"CodeBlock"`
+	Emoji         map[string]string     `yaml:"Emoji"`
+	English       map[string]string     `yaml:"English"`
+	SpellAcronyms bool                  `yaml:"SpellAcronyms"`
+	Substitutions []SubstitutionSetting `yaml:"Substitutions"`
+}
+
+type SubstitutionSetting struct {
+	Pattern string `yaml:"Pattern"`
+	Replace string `yaml:"Replace"`
+}
+
+// Pipeline returns the normalization pipeline of the settings.
+func (s NormalizeSetting) Pipeline() (normalize.Pipeline, error) {
+	var subs []normalize.Substitution
+	for _, sub := range s.Substitutions {
+		subs = append(subs, normalize.Substitution{Pattern: sub.Pattern, Replace: sub.Replace})
+	}
+
+	return normalize.New(normalize.Options{
+		Rules:         s.Rules,
+		URL:           normalize.URLMode(s.URL),
+		LinkWord:      s.LinkWord,
+		CodeBlock:     s.CodeBlock,
+		Emoji:         s.Emoji,
+		English:       s.English,
+		SpellAcronyms: s.SpellAcronyms,
+		Substitutions: subs,
+	})
+}
+
+// ScheduleSetting decides when messages are played. See schedule.Options.
+type ScheduleSetting struct {
+	TimeZone string                  `yaml:"TimeZone"`
+	Windows  []ScheduleWindowSetting `yaml:"Windows"`
+	// Outside is hold (default), drop, reduce or urgent-only
+	Outside       string  `yaml:"Outside"`
+	ReducedVolume float32 `yaml:"ReducedVolume"`
+}
+
+type ScheduleWindowSetting struct {
+	Days  []string `yaml:"Days"`
+	Start string   `yaml:"Start"`
+	End   string   `yaml:"End"`
+}
+
+// Policy returns the scheduling policy of the settings.
+func (s ScheduleSetting) Policy() (*schedule.Policy, error) {
+	var windows []schedule.WindowOptions
+	for _, w := range s.Windows {
+		windows = append(windows, schedule.WindowOptions{Days: w.Days, Start: w.Start, End: w.End})
+	}
+
+	return schedule.New(schedule.Options{
+		TimeZone:      s.TimeZone,
+		Windows:       windows,
+		Outside:       schedule.Action(s.Outside),
+		ReducedVolume: s.ReducedVolume,
+	})
 }
 
 func ReadSettings() (*Setting, error) {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"maps"
 	"regexp"
 	"strings"
 
@@ -10,30 +13,76 @@ import (
 	"github.com/slack-go/slack/socketmode"
 )
 
-func StartSlack(settings SlackSetting) (chan string, chan error) {
+var useridRegexp = regexp.MustCompile(`<@(\S+)>`)
+
+const (
+	// slashCommand is the slash command to speak.
+	// The commands are given as slashCommand-name, such as /say-stop.
+	slashCommand = "/say"
+
+	slackActionCancel = "cancel"
+	slackActionReplay = "replay"
+)
+
+// SlackSource takes texts mentioned to the bot or given by /say.
+// The result of a message is written over the "OK, wait a moment..." message,
+// which has buttons to cancel the message and to replay the last one of the channel.
+// A mention starting with a command name runs the command instead, as /say-<command> does.
+// "replay <id>" and /say-replay speak a message in the history again.
+// The history command and replay see only the messages sent from the same channel.
+// Everything is checked by SlackAccess first, and a rejection is answered with the reason.
+type SlackSource struct {
+	settings   SlackSetting
+	slackAPI   *slack.Client
+	access     *SlackAccess
+	commands   Commands
+	controller *Controller
+	history    *History
+}
+
+// NewSlackSource returns the source of settings. The quiet hours of settings.Access are in timeZone.
+func NewSlackSource(settings SlackSetting, timeZone string, commands Commands, controller *Controller, history *History) (*SlackSource, error) {
 	slackAPI := slack.New(settings.Token, slack.OptionAppLevelToken(settings.AppLevelToken))
 
-	scm := socketmode.New(slackAPI)
+	access, err := NewSlackAccess(settings.Access, timeZone, slackAPI)
+	if err != nil {
+		return nil, fmt.Errorf("Access: %v", err)
+	}
+
+	return &SlackSource{
+		settings:   settings,
+		slackAPI:   slackAPI,
+		access:     access,
+		commands:   commands,
+		controller: controller,
+		history:    history,
+	}, nil
+}
+
+func (s *SlackSource) Name() string {
+	return "slack"
+}
+
+func (s *SlackSource) Start(ctx context.Context, submit func(*Message) error) error {
+	scm := socketmode.New(s.slackAPI)
 
 	go func() {
-		err := scm.Run()
-		if err != nil {
-			fmt.Println(err)
+		err := scm.RunContext(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Slack connection stopped", "error", err)
 		}
 	}()
 
-	botinfo, _ := slackAPI.AuthTest()
-
-	var output = make(chan string)
-	var donechan = make(chan error)
-
-	var useridRegexp = regexp.MustCompile(`<@(\S+)>`)
+	botinfo, err := s.slackAPI.AuthTest()
+	if err != nil {
+		return fmt.Errorf("AuthTest: %v", err)
+	}
 
 	go func() {
 		for ev := range scm.Events {
 			switch ev.Type {
 			case socketmode.EventTypeConnected:
-				fmt.Printf("Start websocket connection with Slack\n")
+				slog.Info("Start websocket connection with Slack")
 			case socketmode.EventTypeEventsAPI:
 				scm.Ack(*ev.Request)
 
@@ -46,53 +95,342 @@ func StartSlack(settings SlackSetting) (chan string, chan error) {
 				case slackevents.CallbackEvent:
 					switch evi := evp.InnerEvent.Data.(type) {
 					case *slackevents.AppMentionEvent:
-						_, ts, _, _ := slackAPI.SendMessage(
-							evi.Channel,
-							slack.MsgOptionAsUser(false),
-							slack.MsgOptionIconEmoji(settings.Icon),
-							slack.MsgOptionText("OK, wait a moment...", false),
-						)
-
-						text := strings.ReplaceAll(evi.Text, fmt.Sprintf("<@%s>", botinfo.UserID), "")
-						text = strings.TrimSpace(text)
-
-						matchstrings := useridRegexp.FindAllStringSubmatch(text, -1)
-
-						for _, m := range matchstrings {
-							info, err := slackAPI.GetUserInfo(m[1])
-							if err != nil {
-								fmt.Println("Failed to get user details: ", err)
-								continue
-							}
-
-							text = strings.ReplaceAll(text, fmt.Sprintf("<@%s>", info.ID), info.Name)
-						}
-
-						output <- text
-						err := <-donechan
-						if err != nil {
-							slackAPI.UpdateMessage(
-								evi.Channel,
-								ts,
-								slack.MsgOptionAsUser(false),
-								slack.MsgOptionIconEmoji(settings.Icon),
-								slack.MsgOptionText(fmt.Sprintf("Error: %s", err.Error()), false),
-							)
-							continue
-						}
-
-						slackAPI.UpdateMessage(
-							evi.Channel,
-							ts,
-							slack.MsgOptionAsUser(false),
-							slack.MsgOptionIconEmoji(settings.Icon),
-							slack.MsgOptionText("Message was successfully sent.", false),
-						)
+						s.handleMention(evi, botinfo.UserID, submit)
 					}
 				}
+			case socketmode.EventTypeSlashCommand:
+				cmd, ok := ev.Data.(slack.SlashCommand)
+				if !ok {
+					scm.Ack(*ev.Request)
+					continue
+				}
+
+				// the answer is shown only to the user
+				answer := s.handleSlashCommand(cmd, submit)
+				if answer == "" {
+					scm.Ack(*ev.Request)
+				} else {
+					scm.Ack(*ev.Request, map[string]interface{}{"text": answer})
+				}
+			case socketmode.EventTypeInteractive:
+				scm.Ack(*ev.Request)
+
+				callback, ok := ev.Data.(slack.InteractionCallback)
+				if !ok {
+					continue
+				}
+				s.handleInteraction(callback, submit)
 			}
 		}
 	}()
 
-	return output, donechan
+	return nil
+}
+
+// RestoreReply sets the reply of a message restored from the queue file.
+func (s *SlackSource) RestoreReply(m *Message) {
+	m.Reply = s.reply
+}
+
+func (s *SlackSource) handleMention(evi *slackevents.AppMentionEvent, botUserID string, submit func(*Message) error) {
+	var thread = evi.ThreadTimeStamp
+	if thread == "" {
+		thread = evi.TimeStamp
+	}
+
+	err := s.access.CheckUser(evi.Channel, evi.User)
+	if err != nil {
+		s.answerInThread(evi.Channel, thread, err.Error())
+		return
+	}
+
+	text := strings.ReplaceAll(evi.Text, fmt.Sprintf("<@%s>", botUserID), "")
+	text = s.replaceUserIDs(strings.TrimSpace(text))
+
+	if id, ok := cutReplayCommand(text); ok {
+		answer := s.replay(evi.Channel, evi.User, id, submit)
+		if answer != "" {
+			s.answerInThread(evi.Channel, thread, answer)
+		}
+		return
+	}
+
+	answer, ok, err := s.runCommand(evi.Channel, text)
+	if ok {
+		if err != nil {
+			answer = fmt.Sprintf("Error: %s", err.Error())
+		}
+		s.slackAPI.SendMessage(
+			evi.Channel,
+			slack.MsgOptionAsUser(false),
+			slack.MsgOptionIconEmoji(s.settings.Icon),
+			slack.MsgOptionText(answer, false),
+		)
+		return
+	}
+
+	options, text := ParseMessageOptions(text)
+	if text == "" {
+		return
+	}
+
+	err = s.access.CheckMessage(evi.User)
+	if err != nil {
+		s.answerInThread(evi.Channel, thread, err.Error())
+		return
+	}
+
+	s.speak(evi.Channel, evi.User, text, options, submit)
+}
+
+// handleSlashCommand speaks the text of /say, or runs the command of /say-<command>.
+// It returns the answer to the user.
+func (s *SlackSource) handleSlashCommand(cmd slack.SlashCommand, submit func(*Message) error) string {
+	err := s.access.CheckUser(cmd.ChannelID, cmd.UserID)
+	if err != nil {
+		return err.Error()
+	}
+
+	var text = s.replaceUserIDs(strings.TrimSpace(cmd.Text))
+
+	if cmd.Command == slashCommand {
+		options, text := ParseMessageOptions(text)
+		if text == "" {
+			return fmt.Sprintf("Usage: %s [options] <text>", slashCommand)
+		}
+
+		err = s.access.CheckMessage(cmd.UserID)
+		if err != nil {
+			return err.Error()
+		}
+
+		err = s.speak(cmd.ChannelID, cmd.UserID, text, options, submit)
+		if err != nil {
+			// the bot may not be in the channel, so the result cannot be written there
+			return "OK, wait a moment..."
+		}
+		return ""
+	}
+
+	name, ok := strings.CutPrefix(cmd.Command, slashCommand+"-")
+	if !ok {
+		return fmt.Sprintf("Unknown command %s", cmd.Command)
+	}
+
+	if id, ok := cutReplayCommand(name + " " + text); ok {
+		return s.replay(cmd.ChannelID, cmd.UserID, id, submit)
+	}
+
+	answer, ok, err := s.runCommand(cmd.ChannelID, name+" "+text)
+	switch {
+	case !ok:
+		return fmt.Sprintf("Unknown command %s", cmd.Command)
+	case err != nil:
+		return fmt.Sprintf("Error: %s", err.Error())
+	}
+	return answer
+}
+
+// handleInteraction handles the buttons of the "OK, wait a moment..." message.
+func (s *SlackSource) handleInteraction(callback slack.InteractionCallback, submit func(*Message) error) {
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	err := s.access.CheckUser(callback.Channel.ID, callback.User.ID)
+	if err != nil {
+		s.answerEphemeral(callback.Channel.ID, callback.User.ID, err.Error())
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch action.ActionID {
+		case slackActionCancel:
+			if !s.controller.Cancel(action.Value) {
+				s.answerEphemeral(callback.Channel.ID, callback.User.ID, "The message has already been spoken.")
+			}
+		case slackActionReplay:
+			// the last message of the channel, as "replay" without an ID
+			answer := s.replay(callback.Channel.ID, callback.User.ID, "", submit)
+			if answer != "" {
+				s.answerEphemeral(callback.Channel.ID, callback.User.ID, answer)
+			}
+		}
+	}
+}
+
+// replaceUserIDs replaces the user mentions in text with the user names.
+func (s *SlackSource) replaceUserIDs(text string) string {
+	matchstrings := useridRegexp.FindAllStringSubmatch(text, -1)
+
+	for _, m := range matchstrings {
+		info, err := s.slackAPI.GetUserInfo(m[1])
+		if err != nil {
+			slog.Warn("Failed to get user details", "error", err)
+			continue
+		}
+
+		text = strings.ReplaceAll(text, fmt.Sprintf("<@%s>", info.ID), info.Name)
+	}
+
+	return text
+}
+
+// runCommand runs the command of text given in channel.
+// The history command lists only the messages of channel, so that a channel does not see the others.
+func (s *SlackSource) runCommand(channel, text string) (answer string, ok bool, err error) {
+	var commands = s.commands
+	if _, ok := commands["history"]; ok {
+		commands = maps.Clone(commands)
+		commands["history"] = s.history.ChannelCommand(channel)
+	}
+	return commands.Run(text)
+}
+
+// cutReplayCommand returns the ID given to "replay", which is empty for the last message.
+func cutReplayCommand(text string) (string, bool) {
+	var fields = strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "replay" || len(fields) > 2 {
+		return "", false
+	}
+	if len(fields) == 1 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+// replay speaks the message of id in the history again, or the last one if id is empty.
+// Only the messages sent from channel can be replayed.
+// The cached sound is used if it is still there.
+// It returns the answer to the user, which is empty if the message was queued.
+func (s *SlackSource) replay(channel, user, id string, submit func(*Message) error) string {
+	var entry HistoryEntry
+	var ok bool
+	if id == "" {
+		var recent = s.history.RecentIn(channel, 1)
+		if ok = len(recent) > 0; ok {
+			entry = recent[0]
+		}
+	} else {
+		entry, ok = s.history.Get(id)
+		ok = ok && entry.Channel == channel
+	}
+	if !ok {
+		return "No such message in the history."
+	}
+
+	err := s.access.CheckMessage(user)
+	if err != nil {
+		return err.Error()
+	}
+
+	err = s.speak(channel, user, entry.Text, entry.Options, submit)
+	if err != nil {
+		return "OK, wait a moment..."
+	}
+	return ""
+}
+
+// speak posts "OK, wait a moment..." to channel and queues the text of user.
+// The text is queued even if the post fails, whose error is returned.
+func (s *SlackSource) speak(channel, user, text string, options MessageOptions, submit func(*Message) error) error {
+	message := NewMessage(s.Name(), text, options)
+	message.Reply = s.reply
+
+	_, ts, _, postErr := s.slackAPI.SendMessage(
+		channel,
+		slack.MsgOptionAsUser(false),
+		slack.MsgOptionIconEmoji(s.settings.Icon),
+		slack.MsgOptionText("OK, wait a moment...", false),
+		slack.MsgOptionBlocks(s.blocks("OK, wait a moment...", message.ID, true)...),
+	)
+
+	message.Meta["channel"] = channel
+	message.Meta["user"] = user
+	message.Meta["ts"] = ts
+
+	err := submit(message)
+	if err != nil {
+		s.reply(message, nil, err)
+	}
+
+	return postErr
+}
+
+// blocks returns text with the buttons.
+// The cancel button is for the message of id, and the replay button is for the last message.
+func (s *SlackSource) blocks(text, id string, cancel bool) []slack.Block {
+	var buttons []slack.BlockElement
+	if cancel {
+		buttons = append(buttons, slack.NewButtonBlockElement(
+			slackActionCancel, id, slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		).WithStyle(slack.StyleDanger))
+	}
+	buttons = append(buttons, slack.NewButtonBlockElement(
+		slackActionReplay, id, slack.NewTextBlockObject(slack.PlainTextType, "Replay the last message", false, false),
+	))
+
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+		slack.NewActionBlock("", buttons...),
+	}
+}
+
+func (s *SlackSource) answerInThread(channel, thread, text string) {
+	s.slackAPI.SendMessage(
+		channel,
+		slack.MsgOptionAsUser(false),
+		slack.MsgOptionIconEmoji(s.settings.Icon),
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(thread),
+	)
+}
+
+func (s *SlackSource) answerEphemeral(channel, user, text string) {
+	s.slackAPI.PostEphemeral(
+		channel,
+		user,
+		slack.MsgOptionIconEmoji(s.settings.Icon),
+		slack.MsgOptionText(text, false),
+	)
+}
+
+// Notify answers text in the thread of the "OK, wait a moment..." message.
+func (s *SlackSource) Notify(m *Message, text string) {
+	var channel, ts = m.Meta["channel"], m.Meta["ts"]
+	if channel == "" || ts == "" {
+		return
+	}
+	s.answerInThread(channel, ts, text)
+}
+
+func (s *SlackSource) reply(m *Message, report PlayReport, err error) {
+	var channel, ts = m.Meta["channel"], m.Meta["ts"]
+	if channel == "" || ts == "" {
+		return
+	}
+
+	if err == nil {
+		err = report.Err()
+	}
+
+	var text string
+	var succeeded = report.Succeeded()
+	switch {
+	case err != nil && len(succeeded) > 0:
+		text = fmt.Sprintf("Message was sent to %s.\nError: %s", strings.Join(succeeded, ", "), err.Error())
+	case err != nil:
+		text = fmt.Sprintf("Error: %s", err.Error())
+	default:
+		text = fmt.Sprintf("Message was successfully sent to %s.", strings.Join(succeeded, ", "))
+	}
+
+	s.slackAPI.UpdateMessage(
+		channel,
+		ts,
+		slack.MsgOptionAsUser(false),
+		slack.MsgOptionIconEmoji(s.settings.Icon),
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(s.blocks(text, m.ID, false)...),
+	)
 }
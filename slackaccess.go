@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/schedule"
+	"github.com/slack-go/slack"
+)
+
+// userGroupCacheTime is how long the members of a user group are kept.
+const userGroupCacheTime = 10 * time.Minute
+
+// SlackAccess decides who can use the bot, where and when.
+type SlackAccess struct {
+	settings SlackAccessSetting
+	slackAPI *slack.Client
+	now      func() time.Time
+
+	// quiet is open outside the quiet hours. It is nil if there are none.
+	quiet *schedule.Policy
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	groups  map[string]userGroupMembers
+}
+
+type userGroupMembers struct {
+	members   []string
+	fetchedAt time.Time
+}
+
+// tokenBucket holds the messages a user can send now.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewSlackAccess returns the access control of settings.
+// The quiet hours are in timeZone, which is the one of Schedule.
+func NewSlackAccess(settings SlackAccessSetting, timeZone string, slackAPI *slack.Client) (*SlackAccess, error) {
+	var a = &SlackAccess{
+		settings: settings,
+		slackAPI: slackAPI,
+		now:      time.Now,
+		buckets:  map[string]*tokenBucket{},
+		groups:   map[string]userGroupMembers{},
+	}
+
+	var err error
+	a.quiet, err = settings.QuietHours.Policy(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("QuietHours: %v", err)
+	}
+
+	if settings.RateLimit.Messages > 0 && settings.RateLimit.Interval <= 0 {
+		return nil, fmt.Errorf("RateLimit.Interval must be positive")
+	}
+
+	return a, nil
+}
+
+// CheckUser returns an error telling why user cannot use the bot in channel, or nil.
+func (a *SlackAccess) CheckUser(channel, user string) error {
+	if len(a.settings.Channels) > 0 && !slices.Contains(a.settings.Channels, channel) {
+		return fmt.Errorf("The bot is not available in this channel.")
+	}
+
+	if slices.Contains(a.settings.DeniedUsers, user) || a.inUserGroups(a.settings.DeniedUserGroups, user) {
+		return fmt.Errorf("You are not allowed to use the bot.")
+	}
+
+	if len(a.settings.Users) == 0 && len(a.settings.UserGroups) == 0 {
+		return nil
+	}
+	if slices.Contains(a.settings.Users, user) || a.inUserGroups(a.settings.UserGroups, user) {
+		return nil
+	}
+	return fmt.Errorf("You are not allowed to use the bot.")
+}
+
+// CheckMessage returns an error telling why user cannot send a message now, or nil.
+// A message which passes is counted for the rate limit.
+func (a *SlackAccess) CheckMessage(user string) error {
+	var now = a.now()
+
+	if a.quiet != nil && !a.quiet.Open(now) {
+		return fmt.Errorf("It is quiet hours (%s-%s) now, so the message was not taken.", a.settings.QuietHours.Start, a.settings.QuietHours.End)
+	}
+
+	var limit = a.settings.RateLimit
+	if limit.Messages <= 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// a token comes back every Interval/Messages seconds
+	var refill = time.Duration(float64(limit.Interval) * float64(time.Second) / float64(limit.Messages))
+
+	bucket, ok := a.buckets[user]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Messages), updated: now}
+		a.buckets[user] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit.Messages), bucket.tokens+float64(now.Sub(bucket.updated))/float64(refill))
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		var wait = time.Duration((1 - bucket.tokens) * float64(refill)).Round(time.Second)
+		return fmt.Errorf("You are sending messages too fast. Try again in %s.", wait)
+	}
+	bucket.tokens--
+
+	return nil
+}
+
+// inUserGroups returns whether user is a member of any of groups.
+func (a *SlackAccess) inUserGroups(groups []string, user string) bool {
+	for _, group := range groups {
+		if slices.Contains(a.userGroupMembers(group), user) {
+			return true
+		}
+	}
+	return false
+}
+
+// userGroupMembers returns the members of group, fetching them when the cache is old.
+// If the fetch fails, the old members are used.
+func (a *SlackAccess) userGroupMembers(group string) []string {
+	a.mu.Lock()
+	cached, ok := a.groups[group]
+	a.mu.Unlock()
+
+	if ok && a.now().Sub(cached.fetchedAt) < userGroupCacheTime {
+		return cached.members
+	}
+
+	members, err := a.slackAPI.GetUserGroupMembers(group)
+	if err != nil {
+		slog.Warn("Failed to get the members of the user group", "group", group, "error", err)
+		return cached.members
+	}
+
+	a.mu.Lock()
+	a.groups[group] = userGroupMembers{members: members, fetchedAt: a.now()}
+	a.mu.Unlock()
+
+	return members
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// TTSOptions are the per-message parameters of synthesis.
+type TTSOptions struct {
+	// Voice is a speaker name or ID. Its meaning depends on the engine.
+	// The default voice of the engine is used if it is empty.
+	Voice string
+	// Prosody overrides the default prosody of the engine.
+	// Engines which do not use AudioQuery ignore it.
+	Prosody Prosody
+}
+
+// TTSEngine synthesizes speech.
+type TTSEngine interface {
+	// Synthesize returns the speech of text as WAV.
+	Synthesize(text string, options TTSOptions) ([]byte, error)
+	// Close releases the resources of the engine.
+	Close()
+}
+
+// CacheKeyer is implemented by the engines whose sounds can be cached.
+// CacheKey returns everything which decides the sound of text, such as the text as the engine reads it,
+// the speaker, the prosody and the version of the engine.
+type CacheKeyer interface {
+	CacheKey(text string, options TTSOptions) ([]string, error)
+}
+
+// voicevoxCacheKey returns the cache key of the VOICEVOX engines.
+func voicevoxCacheKey(version string, speakerID uint32, prosody Prosody, text string) []string {
+	b, _ := json.Marshal(prosody)
+	return []string{"voicevox", version, strconv.FormatUint(uint64(speakerID), 10), string(b), text}
+}
+
+// NewTTSEngine returns the engine chosen by TTS.Engine.
+func NewTTSEngine(settings *Setting) (TTSEngine, error) {
+	switch settings.TTS.Engine {
+	case "", "voicevox":
+		return newVoicevoxEngine(settings.Voicevox)
+	case "voicevox-engine":
+		return newVoicevoxHTTPEngine(settings.Voicevox, settings.TTS.VoicevoxEngine)
+	case "command":
+		return NewCommandEngine(settings.TTS.Command)
+	}
+	return nil, fmt.Errorf("Unknown TTS engine %q", settings.TTS.Engine)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultCommandTimeout is how long a command can run when TTS.Command.Timeout is not set.
+	defaultCommandTimeout = 30 * time.Second
+	// commandWaitDelay is how long the output is waited for after the command is killed,
+	// in case a child process keeps it open.
+	commandWaitDelay = time.Second
+)
+
+// CommandEngine synthesizes speech with an external command such as open_jtalk or espeak-ng.
+//
+// In the arguments, "{text}", "{voice}" and "{output}" are replaced with the text,
+// the voice and the path of a temporary WAV file.
+// Without "{text}", the text is written to the standard input,
+// and without "{output}", the WAV is read from the standard output.
+//
+// The text and the voice come from the senders, so they must not be taken as options of the command.
+// "--" is put before an argument which is just "{text}", and a text or a voice starting with "-" is rejected
+// if it is given in the arguments. The standard input is safer, since nothing in it is parsed as an option.
+type CommandEngine struct {
+	settings CommandTTSSetting
+	// tempDir keeps the output files, and is removed by Close
+	tempDir string
+}
+
+func NewCommandEngine(settings CommandTTSSetting) (*CommandEngine, error) {
+	if len(settings.Command) == 0 {
+		return nil, fmt.Errorf("TTS.Command.Command is empty")
+	}
+
+	_, err := exec.LookPath(settings.Command[0])
+	if err != nil {
+		return nil, fmt.Errorf("LookPath: %v", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "GoogleHomeCommandTTS*")
+	if err != nil {
+		return nil, fmt.Errorf("MkdirTemp: %v", err)
+	}
+
+	return &CommandEngine{settings: settings, tempDir: tempDir}, nil
+}
+
+func (e *CommandEngine) voice(options TTSOptions) string {
+	if options.Voice != "" {
+		return options.Voice
+	}
+	return e.settings.Voice
+}
+
+func (e *CommandEngine) CacheKey(text string, options TTSOptions) ([]string, error) {
+	return append([]string{"command", e.voice(options), text}, e.settings.Command...), nil
+}
+
+func (e *CommandEngine) Synthesize(text string, options TTSOptions) ([]byte, error) {
+	var voice = e.voice(options)
+
+	dir, err := os.MkdirTemp(e.tempDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var output = filepath.Join(dir, "output.wav")
+
+	var replacer = strings.NewReplacer("{text}", text, "{voice}", voice, "{output}", output)
+
+	var args []string
+	var textInArgs, outputInArgs bool
+	for i, arg := range e.settings.Command[1:] {
+		if strings.Contains(arg, "{text}") {
+			textInArgs = true
+			if strings.HasPrefix(strings.TrimSpace(text), "-") {
+				return nil, fmt.Errorf("The text must not start with \"-\"")
+			}
+			// the options end here, unless the command line ends them already
+			if arg == "{text}" && (i == 0 || e.settings.Command[i] != "--") {
+				args = append(args, "--")
+			}
+		}
+		if strings.Contains(arg, "{voice}") && strings.HasPrefix(voice, "-") {
+			return nil, fmt.Errorf("The voice must not start with \"-\"")
+		}
+		outputInArgs = outputInArgs || strings.Contains(arg, "{output}")
+		args = append(args, replacer.Replace(arg))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.settings.timeout())
+	defer cancel()
+
+	var cmd = exec.CommandContext(ctx, e.settings.Command[0], args...)
+	cmd.WaitDelay = commandWaitDelay
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if !textInArgs {
+		cmd.Stdin = strings.NewReader(text)
+	}
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s: timed out after %s", e.settings.Command[0], e.settings.timeout())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", e.settings.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	if !outputInArgs {
+		return stdout.Bytes(), nil
+	}
+
+	wav, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %v", err)
+	}
+
+	return wav, nil
+}
+
+// Close removes the files of the commands which are still running.
+func (e *CommandEngine) Close() {
+	os.RemoveAll(e.tempDir)
+}
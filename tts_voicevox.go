@@ -0,0 +1,167 @@
+//go:build !novoicevox
+// +build !novoicevox
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+// voicevoxEngine synthesizes speech with VOICEVOX Core.
+type voicevoxEngine struct {
+	settings VoicevoxSetting
+	// metas and version are not changed after newVoicevoxEngine
+	metas   []voicevox.VoicevoxSpeakerMeta
+	version string
+	models  *ModelManager
+
+	// dictMu guards dict, so that CacheKey does not wait for a synthesis
+	dictMu sync.RWMutex
+	dict   *voicevox.UserDict
+
+	// mu is held while VOICEVOX Core synthesizes, since it is not called concurrently
+	mu sync.Mutex
+	// closed is set when VOICEVOX Core is finalized
+	closed bool
+}
+
+func newVoicevoxEngine(settings VoicevoxSetting) (TTSEngine, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	err := voicevox.Initialize(voicevox.VoicevoxInitializeOptions{
+		AccelerationMode: voicevox.VOICEVOX_ACCELERATION_MODE_AUTO,
+		CpuNumThreads:    settings.CpuNumThreads,
+		LoadAllModels:    false,
+		OpenJtalkDictDir: settings.OpenJtalkDictDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Initialize: %v", err)
+	}
+
+	var models = NewModelManager(
+		func(speakerID uint32) (bool, error) { return voicevox.IsModelLoaded(speakerID), nil },
+		voicevox.LoadModel,
+	)
+	err = models.Use(settings.SpeakerID)
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := voicevox.ParseMetas(voicevox.GetMetasJSON())
+	if err != nil {
+		return nil, fmt.Errorf("ParseMetas: %v", err)
+	}
+
+	return &voicevoxEngine{
+		settings: settings,
+		metas:    metas,
+		version:  voicevox.GetVersion(),
+		models:   models,
+	}, nil
+}
+
+// speakerID returns the speaker of voice, or the default speaker if voice is empty.
+func (e *voicevoxEngine) speakerID(voice string) (uint32, error) {
+	if voice == "" {
+		return e.settings.SpeakerID, nil
+	}
+	id, err := voicevox.FindSpeakerID(e.metas, voice)
+	if err != nil {
+		return 0, fmt.Errorf("FindSpeakerID: %v", err)
+	}
+	return id, nil
+}
+
+// replaceWords replaces the words of the user dictionary in text with their pronunciations.
+func (e *voicevoxEngine) replaceWords(text string) string {
+	e.dictMu.RLock()
+	defer e.dictMu.RUnlock()
+
+	if e.dict == nil {
+		return text
+	}
+	return e.dict.ReplaceSurfaces(text)
+}
+
+func (e *voicevoxEngine) CacheKey(text string, options TTSOptions) ([]string, error) {
+	speakerID, err := e.speakerID(options.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	text = e.replaceWords(text)
+
+	return voicevoxCacheKey(e.version, speakerID, e.settings.Prosody.Merge(options.Prosody), text), nil
+}
+
+func (e *voicevoxEngine) Synthesize(text string, options TTSOptions) ([]byte, error) {
+	speakerID, err := e.speakerID(options.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	text = e.replaceWords(text)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil, fmt.Errorf("VOICEVOX Core is finalized")
+	}
+
+	err = e.models.Use(speakerID)
+	if err != nil {
+		return nil, err
+	}
+
+	audioQuery, err := voicevox.AudioQuery(text, speakerID, voicevox.VoicevoxAudioQueryOptions{Kana: false})
+	if err != nil {
+		return nil, fmt.Errorf("AudioQuery: %v", err)
+	}
+
+	audioQuery, err = applyProsody(audioQuery, e.settings.Prosody.Merge(options.Prosody))
+	if err != nil {
+		return nil, fmt.Errorf("applyProsody: %v", err)
+	}
+
+	wav, err := voicevox.Synthesis(audioQuery, speakerID, voicevox.VoicevoxSynthesisOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Synthesis: %v", err)
+	}
+
+	return wav, nil
+}
+
+// UseUserDict makes the engine read the words in dict.
+// VOICEVOX Core 0.14 cannot give a user dictionary to Open JTalk,
+// so the words are replaced with their pronunciations before AudioQuery.
+func (e *voicevoxEngine) UseUserDict(dict *voicevox.UserDict) error {
+	e.dictMu.Lock()
+	defer e.dictMu.Unlock()
+
+	e.dict = dict
+	return nil
+}
+
+// ReplacesSurfaces reports that the accent type, the word type and the priority of the words are ignored,
+// since UseUserDict only replaces the words with their pronunciations.
+func (e *voicevoxEngine) ReplacesSurfaces() bool {
+	return true
+}
+
+// Close finalizes VOICEVOX Core after the synthesis in progress.
+func (e *voicevoxEngine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	voicevox.Finalize()
+}
@@ -0,0 +1,12 @@
+//go:build novoicevox
+// +build novoicevox
+
+package main
+
+import (
+	"fmt"
+)
+
+func newVoicevoxEngine(settings VoicevoxSetting) (TTSEngine, error) {
+	return nil, fmt.Errorf("This binary was built with the novoicevox tag, so VOICEVOX Core is not available. Choose another TTS.Engine.")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox/engine"
+)
+
+// voicevoxHTTPEngine synthesizes speech with a VOICEVOX Engine server.
+type voicevoxHTTPEngine struct {
+	settings VoicevoxSetting
+	client   *engine.Client
+	metas    []voicevox.VoicevoxSpeakerMeta
+	version  string
+	models   *ModelManager
+
+	mu sync.Mutex
+	// synced are the UUIDs in the engine of the words given by UseUserDict, by surface
+	synced map[string]string
+	// words are the words given by UseUserDict in JSON, which change the sounds
+	words string
+}
+
+func newVoicevoxHTTPEngine(settings VoicevoxSetting, engineSettings VoicevoxEngineSetting) (TTSEngine, error) {
+	if engineSettings.URL == "" {
+		return nil, fmt.Errorf("TTS.VoicevoxEngine.URL is empty")
+	}
+
+	var client = engine.NewClient(engineSettings.URL)
+
+	metasJSON, err := client.GetMetasJSON()
+	if err != nil {
+		return nil, fmt.Errorf("GetMetasJSON: %v", err)
+	}
+
+	metas, err := voicevox.ParseMetas(metasJSON)
+	if err != nil {
+		return nil, fmt.Errorf("ParseMetas: %v", err)
+	}
+
+	version, err := client.GetVersion()
+	if err != nil {
+		return nil, fmt.Errorf("GetVersion: %v", err)
+	}
+
+	return &voicevoxHTTPEngine{
+		settings: settings,
+		client:   client,
+		metas:    metas,
+		version:  version,
+		models:   NewModelManager(client.IsModelLoaded, client.LoadModel),
+	}, nil
+}
+
+// speakerID returns the speaker of voice, or the default speaker if voice is empty.
+func (e *voicevoxHTTPEngine) speakerID(voice string) (uint32, error) {
+	if voice == "" {
+		return e.settings.SpeakerID, nil
+	}
+	id, err := voicevox.FindSpeakerID(e.metas, voice)
+	if err != nil {
+		return 0, fmt.Errorf("FindSpeakerID: %v", err)
+	}
+	return id, nil
+}
+
+func (e *voicevoxHTTPEngine) CacheKey(text string, options TTSOptions) ([]string, error) {
+	speakerID, err := e.speakerID(options.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	var words = e.words
+	e.mu.Unlock()
+
+	return append(voicevoxCacheKey(e.version, speakerID, e.settings.Prosody.Merge(options.Prosody), text), words), nil
+}
+
+func (e *voicevoxHTTPEngine) Synthesize(text string, options TTSOptions) ([]byte, error) {
+	speakerID, err := e.speakerID(options.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.models.Use(speakerID)
+	if err != nil {
+		return nil, err
+	}
+
+	audioQuery, err := e.client.AudioQuery(text, speakerID, voicevox.VoicevoxAudioQueryOptions{Kana: false})
+	if err != nil {
+		return nil, fmt.Errorf("AudioQuery: %v", err)
+	}
+
+	audioQuery, err = applyProsody(audioQuery, e.settings.Prosody.Merge(options.Prosody))
+	if err != nil {
+		return nil, fmt.Errorf("applyProsody: %v", err)
+	}
+
+	wav, err := e.client.Synthesis(audioQuery, speakerID, voicevox.VoicevoxSynthesisOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Synthesis: %v", err)
+	}
+
+	return wav, nil
+}
+
+// UseUserDict adds the words in dict to the user dictionary of the engine,
+// and deletes the words which were given before and are not in dict any more.
+// The other words in the engine are left as they are.
+func (e *voicevoxHTTPEngine) UseUserDict(dict *voicevox.UserDict) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	remote, err := e.client.GetUserDict()
+	if err != nil {
+		return fmt.Errorf("GetUserDict: %v", err)
+	}
+
+	// the engine keeps surfaces in full width
+	var remoteUUIDs = map[string]string{}
+	for uuid, word := range remote {
+		remoteUUIDs[toFullWidth(word.Surface)] = uuid
+	}
+
+	var synced = map[string]string{}
+	var words = dict.Words()
+	for _, word := range words {
+		var surface = toFullWidth(word.Surface)
+		var priority = int(word.Priority)
+		var params = engine.UserDictWordParams{
+			Surface:       word.Surface,
+			Pronunciation: word.Pronunciation,
+			AccentType:    word.AccentType,
+			WordType:      word.WordType.String(),
+			Priority:      &priority,
+		}
+
+		if uuid, ok := remoteUUIDs[surface]; ok {
+			err = e.client.UpdateUserDictWord(uuid, params)
+			if err != nil {
+				return fmt.Errorf("UpdateUserDictWord: %s: %v", word.Surface, err)
+			}
+			synced[surface] = uuid
+			continue
+		}
+
+		uuid, err := e.client.AddUserDictWord(params)
+		if err != nil {
+			return fmt.Errorf("AddUserDictWord: %s: %v", word.Surface, err)
+		}
+		synced[surface] = uuid
+	}
+
+	for surface, uuid := range e.synced {
+		if _, ok := synced[surface]; ok {
+			continue
+		}
+		if _, ok := remote[uuid]; !ok {
+			continue
+		}
+
+		err = e.client.DeleteUserDictWord(uuid)
+		if err != nil {
+			return fmt.Errorf("DeleteUserDictWord: %s: %v", surface, err)
+		}
+	}
+
+	e.synced = synced
+	b, _ := json.Marshal(words)
+	e.words = string(b)
+	return nil
+}
+
+// toFullWidth converts ASCII characters to their full width forms as VOICEVOX Engine does.
+func toFullWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '!' && r <= '~' {
+			return r + 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+func (e *voicevoxHTTPEngine) Close() {}
@@ -0,0 +1,25 @@
+package voicevox
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseAudioQuery parses the JSON returned by AudioQuery.
+func ParseAudioQuery(audioQueryJSON string) (*VoicevoxAudioQuery, error) {
+	var query VoicevoxAudioQuery
+	err := json.Unmarshal([]byte(audioQueryJSON), &query)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal: %v", err)
+	}
+	return &query, nil
+}
+
+// JSON returns the JSON to be passed to Synthesis.
+func (q *VoicevoxAudioQuery) JSON() (string, error) {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return "", fmt.Errorf("Marshal: %v", err)
+	}
+	return string(b), nil
+}
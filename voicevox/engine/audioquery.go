@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"encoding/json"
+)
+
+// VOICEVOX Engine names the top level fields of AudioQuery in camelCase,
+// while VOICEVOX Core uses snake_case. The fields of accent phrases and moras are the same.
+var coreToEngineKeys = map[string]string{
+	"speed_scale":          "speedScale",
+	"pitch_scale":          "pitchScale",
+	"intonation_scale":     "intonationScale",
+	"volume_scale":         "volumeScale",
+	"pre_phoneme_length":   "prePhonemeLength",
+	"post_phoneme_length":  "postPhonemeLength",
+	"output_sampling_rate": "outputSamplingRate",
+	"output_stereo":        "outputStereo",
+}
+
+var engineToCoreKeys = func() map[string]string {
+	var m = map[string]string{}
+	for core, engine := range coreToEngineKeys {
+		m[engine] = core
+	}
+	return m
+}()
+
+func convertAudioQueryKeys(audioQueryJSON []byte, keys map[string]string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(audioQueryJSON, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var converted = make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		if k, ok := keys[key]; ok {
+			key = k
+		}
+		converted[key] = value
+	}
+
+	return json.Marshal(converted)
+}
@@ -0,0 +1,165 @@
+// Package engine is a client of the VOICEVOX Engine HTTP API.
+// It offers the same operations as the voicevox package, so the notifier can
+// synthesize speech without linking VOICEVOX Core.
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+type Client struct {
+	baseURL string
+	// HTTPClient is used for every request. It can be replaced, e.g. in tests.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the engine at baseURL (e.g. "http://localhost:50021").
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// GetVersion returns the version of the engine.
+func (c *Client) GetVersion() (string, error) {
+	var version string
+	err := c.getJSON("/version", nil, &version)
+	if err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// GetMetasJSON returns the speakers in the same format as voicevox.GetMetasJSON.
+func (c *Client) GetMetasJSON() (string, error) {
+	b, err := c.do(http.MethodGet, "/speakers", nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// IsModelLoaded reports whether the model of speakerID is initialized.
+func (c *Client) IsModelLoaded(speakerID uint32) (bool, error) {
+	var loaded bool
+	err := c.getJSON("/is_initialized_speaker", speakerQuery(speakerID), &loaded)
+	if err != nil {
+		return false, err
+	}
+	return loaded, nil
+}
+
+// LoadModel initializes the model of speakerID.
+func (c *Client) LoadModel(speakerID uint32) error {
+	_, err := c.do(http.MethodPost, "/initialize_speaker", speakerQuery(speakerID), nil, "")
+	return err
+}
+
+// AudioQuery returns the AudioQuery of text.
+// The JSON is in the format of VOICEVOX Core (snake_case), so that it can be used
+// in the same way as voicevox.AudioQuery.
+func (c *Client) AudioQuery(text string, speakerID uint32, options voicevox.VoicevoxAudioQueryOptions) (string, error) {
+	if options.Kana {
+		return "", fmt.Errorf("AudioQuery: kana input is not supported by VOICEVOX Engine")
+	}
+
+	var query = speakerQuery(speakerID)
+	query.Set("text", text)
+
+	b, err := c.do(http.MethodPost, "/audio_query", query, nil, "")
+	if err != nil {
+		return "", err
+	}
+
+	coreJSON, err := convertAudioQueryKeys(b, engineToCoreKeys)
+	if err != nil {
+		return "", fmt.Errorf("AudioQuery: %v", err)
+	}
+
+	return string(coreJSON), nil
+}
+
+// Synthesis returns the WAV of an AudioQuery in the format of VOICEVOX Core.
+func (c *Client) Synthesis(audioQueryJSON string, speakerID uint32, options voicevox.VoicevoxSynthesisOptions) ([]byte, error) {
+	engineJSON, err := convertAudioQueryKeys([]byte(audioQueryJSON), coreToEngineKeys)
+	if err != nil {
+		return nil, fmt.Errorf("Synthesis: %v", err)
+	}
+
+	var query = speakerQuery(speakerID)
+	query.Set("enable_interrogative_upspeak", strconv.FormatBool(options.EnableInterrogativeUpspeak))
+
+	return c.do(http.MethodPost, "/synthesis", query, engineJSON, "application/json")
+}
+
+// TTS runs AudioQuery and Synthesis at once.
+func (c *Client) TTS(text string, speakerID uint32, options voicevox.VoicevoxTtsOptions) ([]byte, error) {
+	audioQuery, err := c.AudioQuery(text, speakerID, voicevox.VoicevoxAudioQueryOptions{Kana: options.Kana})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Synthesis(audioQuery, speakerID, voicevox.VoicevoxSynthesisOptions{
+		EnableInterrogativeUpspeak: options.EnableInterrogativeUpspeak,
+	})
+}
+
+func speakerQuery(speakerID uint32) url.Values {
+	return url.Values{"speaker": []string{strconv.FormatUint(uint64(speakerID), 10)}}
+}
+
+func (c *Client) getJSON(path string, query url.Values, v interface{}) error {
+	b, err := c.do(http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("%s: Unmarshal: %v", path, err)
+	}
+	return nil
+}
+
+func (c *Client) do(method, path string, query url.Values, body []byte, contentType string) ([]byte, error) {
+	var u = c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: NewRequest: %v", path, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: ReadAll: %v", path, err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s: %s: %s", path, resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	return b, nil
+}
@@ -0,0 +1,360 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kmc-jp/GoogleHomeNotifier/voicevox"
+)
+
+// stubRequest is a request the stub engine received.
+type stubRequest struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        string
+}
+
+// newStubEngine serves handlers by "METHOD /path", and records every request.
+// A request without a handler is answered with 404.
+func newStubEngine(t *testing.T, handlers map[string]http.HandlerFunc) (*Client, *[]stubRequest) {
+	t.Helper()
+
+	var requests []stubRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, stubRequest{
+			method:      r.Method,
+			path:        r.URL.Path,
+			query:       r.URL.Query(),
+			contentType: r.Header.Get("Content-Type"),
+			body:        string(body),
+		})
+
+		handler, ok := handlers[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, `{"detail":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL + "/"), &requests
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func assertQuery(t *testing.T, got url.Values, want map[string]string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got.Get(key) != value {
+			t.Errorf("query %s = %q, want %q", key, got.Get(key), value)
+		}
+	}
+}
+
+const engineAudioQuery = `{"accent_phrases":[{"moras":[{"text":"コ","vowel":"o","pitch":5.5}],"accent":1}],"speedScale":1.2,"pitchScale":0,"intonationScale":1,"volumeScale":1,"prePhonemeLength":0.1,"postPhonemeLength":0.1,"outputSamplingRate":24000,"outputStereo":false,"kana":"コ'"}`
+
+func TestAudioQuery(t *testing.T) {
+	client, requests := newStubEngine(t, map[string]http.HandlerFunc{
+		"POST /audio_query": respond(http.StatusOK, engineAudioQuery),
+	})
+
+	audioQuery, err := client.AudioQuery("こんにちは", 3, voicevox.VoicevoxAudioQueryOptions{})
+	if err != nil {
+		t.Fatalf("AudioQuery: %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(*requests))
+	}
+	var req = (*requests)[0]
+	if req.method != http.MethodPost || req.path != "/audio_query" {
+		t.Errorf("request = %s %s, want POST /audio_query", req.method, req.path)
+	}
+	assertQuery(t, req.query, map[string]string{"speaker": "3", "text": "こんにちは"})
+
+	// the keys are in the format of VOICEVOX Core
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal([]byte(audioQuery), &fields)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	for _, key := range []string{"speed_scale", "pitch_scale", "intonation_scale", "volume_scale", "pre_phoneme_length", "post_phoneme_length", "output_sampling_rate", "output_stereo", "accent_phrases", "kana"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("AudioQuery has no %s: %s", key, audioQuery)
+		}
+	}
+	if string(fields["speed_scale"]) != "1.2" {
+		t.Errorf("speed_scale = %s, want 1.2", fields["speed_scale"])
+	}
+	if _, ok := fields["speedScale"]; ok {
+		t.Errorf("AudioQuery still has speedScale: %s", audioQuery)
+	}
+
+	// and can be read as voicevox.AudioQuery
+	_, err = voicevox.ParseAudioQuery(audioQuery)
+	if err != nil {
+		t.Errorf("ParseAudioQuery: %v", err)
+	}
+}
+
+func TestAudioQueryKana(t *testing.T) {
+	client, requests := newStubEngine(t, nil)
+
+	_, err := client.AudioQuery("コンニチワ'", 3, voicevox.VoicevoxAudioQueryOptions{Kana: true})
+	if err == nil {
+		t.Error("AudioQuery succeeded with kana")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("%d requests were sent for kana", len(*requests))
+	}
+}
+
+func TestSynthesis(t *testing.T) {
+	client, requests := newStubEngine(t, map[string]http.HandlerFunc{
+		"POST /synthesis": respond(http.StatusOK, "RIFF....WAVE"),
+	})
+
+	var coreAudioQuery = `{"accent_phrases":[],"speed_scale":1.5,"pitch_scale":0.1,"intonation_scale":1,"volume_scale":0.8,"pre_phoneme_length":0.1,"post_phoneme_length":0.2,"output_sampling_rate":24000,"output_stereo":false,"kana":""}`
+
+	wav, err := client.Synthesis(coreAudioQuery, 8, voicevox.VoicevoxSynthesisOptions{EnableInterrogativeUpspeak: true})
+	if err != nil {
+		t.Fatalf("Synthesis: %v", err)
+	}
+	if string(wav) != "RIFF....WAVE" {
+		t.Errorf("Synthesis = %q, want the body of the response", wav)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(*requests))
+	}
+	var req = (*requests)[0]
+	if req.method != http.MethodPost || req.path != "/synthesis" {
+		t.Errorf("request = %s %s, want POST /synthesis", req.method, req.path)
+	}
+	assertQuery(t, req.query, map[string]string{"speaker": "8", "enable_interrogative_upspeak": "true"})
+	if req.contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", req.contentType)
+	}
+
+	// the body is in the format of VOICEVOX Engine
+	var body map[string]interface{}
+	err = json.Unmarshal([]byte(req.body), &body)
+	if err != nil {
+		t.Fatalf("Unmarshal the body: %v", err)
+	}
+	var want = map[string]interface{}{
+		"speedScale":         1.5,
+		"pitchScale":         0.1,
+		"intonationScale":    1.0,
+		"volumeScale":        0.8,
+		"prePhonemeLength":   0.1,
+		"postPhonemeLength":  0.2,
+		"outputSamplingRate": 24000.0,
+		"outputStereo":       false,
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("body %s = %v, want %v", key, body[key], value)
+		}
+	}
+	if _, ok := body["speed_scale"]; ok {
+		t.Errorf("body still has speed_scale: %s", req.body)
+	}
+}
+
+func TestTTS(t *testing.T) {
+	client, requests := newStubEngine(t, map[string]http.HandlerFunc{
+		"POST /audio_query": respond(http.StatusOK, engineAudioQuery),
+		"POST /synthesis":   respond(http.StatusOK, "RIFF"),
+	})
+
+	wav, err := client.TTS("こ", 1, voicevox.VoicevoxTtsOptions{})
+	if err != nil {
+		t.Fatalf("TTS: %v", err)
+	}
+	if string(wav) != "RIFF" {
+		t.Errorf("TTS = %q, want RIFF", wav)
+	}
+
+	if len(*requests) != 2 || (*requests)[0].path != "/audio_query" || (*requests)[1].path != "/synthesis" {
+		t.Fatalf("requests = %v, want /audio_query and /synthesis", *requests)
+	}
+	assertQuery(t, (*requests)[1].query, map[string]string{"speaker": "1", "enable_interrogative_upspeak": "false"})
+	if !strings.Contains((*requests)[1].body, `"speedScale":1.2`) {
+		t.Errorf("/synthesis got %s, want the AudioQuery of /audio_query", (*requests)[1].body)
+	}
+}
+
+func TestGetMetasJSON(t *testing.T) {
+	const speakers = `[{"name":"ずんだもん","speaker_uuid":"388f246b-8c41-4ac1-8e2d-5d79f3ff56d9","styles":[{"name":"ノーマル","id":3}],"version":"0.14.4"}]`
+
+	client, requests := newStubEngine(t, map[string]http.HandlerFunc{
+		"GET /speakers": respond(http.StatusOK, speakers),
+	})
+
+	metas, err := client.GetMetasJSON()
+	if err != nil {
+		t.Fatalf("GetMetasJSON: %v", err)
+	}
+	if metas != speakers {
+		t.Errorf("GetMetasJSON = %s, want the body of /speakers", metas)
+	}
+	if len(*requests) != 1 || (*requests)[0].method != http.MethodGet || len((*requests)[0].query) != 0 {
+		t.Errorf("requests = %v, want GET /speakers without a query", *requests)
+	}
+}
+
+func TestUserDictWord(t *testing.T) {
+	const uuid = "c4ffd4f4-3e1f-4b6e-8b6e-6d5b8a0d1f2e"
+
+	client, requests := newStubEngine(t, map[string]http.HandlerFunc{
+		"POST /user_dict_word":           respond(http.StatusOK, `"`+uuid+`"`),
+		"PUT /user_dict_word/" + uuid:    respond(http.StatusNoContent, ""),
+		"DELETE /user_dict_word/" + uuid: respond(http.StatusNoContent, ""),
+		"GET /user_dict":                 respond(http.StatusOK, `{"`+uuid+`":{"surface":"ＫＭＣ","pronunciation":"ケーエムシー","accent_type":1,"priority":5,"part_of_speech":"名詞","mora_count":5}}`),
+	})
+
+	var priority = 7
+	got, err := client.AddUserDictWord(UserDictWordParams{
+		Surface:       "KMC",
+		Pronunciation: "ケーエムシー",
+		AccentType:    1,
+		WordType:      "PROPER_NOUN",
+		Priority:      &priority,
+	})
+	if err != nil {
+		t.Fatalf("AddUserDictWord: %v", err)
+	}
+	if got != uuid {
+		t.Errorf("AddUserDictWord = %q, want %q", got, uuid)
+	}
+	assertQuery(t, (*requests)[0].query, map[string]string{
+		"surface":       "KMC",
+		"pronunciation": "ケーエムシー",
+		"accent_type":   "1",
+		"word_type":     "PROPER_NOUN",
+		"priority":      "7",
+	})
+
+	err = client.UpdateUserDictWord(uuid, UserDictWordParams{Surface: "KMC", Pronunciation: "ケーエムシー", AccentType: 0})
+	if err != nil {
+		t.Fatalf("UpdateUserDictWord: %v", err)
+	}
+	// the engine defaults are used without WordType and Priority
+	assertQuery(t, (*requests)[1].query, map[string]string{
+		"surface":       "KMC",
+		"pronunciation": "ケーエムシー",
+		"accent_type":   "0",
+	})
+
+	words, err := client.GetUserDict()
+	if err != nil {
+		t.Fatalf("GetUserDict: %v", err)
+	}
+	if words[uuid].Pronunciation != "ケーエムシー" || words[uuid].Priority != 5 {
+		t.Errorf("GetUserDict = %v", words)
+	}
+
+	err = client.DeleteUserDictWord(uuid)
+	if err != nil {
+		t.Fatalf("DeleteUserDictWord: %v", err)
+	}
+
+	var methods []string
+	for _, req := range *requests {
+		methods = append(methods, req.method+" "+req.path)
+	}
+	var want = []string{
+		"POST /user_dict_word",
+		"PUT /user_dict_word/" + uuid,
+		"GET /user_dict",
+		"DELETE /user_dict_word/" + uuid,
+	}
+	if strings.Join(methods, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", methods, want)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	const detail = `{"detail":"something went wrong"}`
+
+	client, _ := newStubEngine(t, map[string]http.HandlerFunc{
+		"POST /audio_query":    respond(http.StatusUnprocessableEntity, detail),
+		"POST /synthesis":      respond(http.StatusInternalServerError, detail),
+		"GET /speakers":        respond(http.StatusServiceUnavailable, detail),
+		"POST /user_dict_word": respond(http.StatusUnprocessableEntity, detail),
+	})
+
+	var tests = []struct {
+		name   string
+		call   func() error
+		path   string
+		status string
+	}{
+		{"AudioQuery", func() error {
+			_, err := client.AudioQuery("こんにちは", 3, voicevox.VoicevoxAudioQueryOptions{})
+			return err
+		}, "/audio_query", "422"},
+		{"Synthesis", func() error {
+			_, err := client.Synthesis(`{"speed_scale":1}`, 3, voicevox.VoicevoxSynthesisOptions{})
+			return err
+		}, "/synthesis", "500"},
+		{"GetMetasJSON", func() error {
+			_, err := client.GetMetasJSON()
+			return err
+		}, "/speakers", "503"},
+		{"AddUserDictWord", func() error {
+			_, err := client.AddUserDictWord(UserDictWordParams{Surface: "KMC", Pronunciation: "ケーエムシー"})
+			return err
+		}, "/user_dict_word", "422"},
+		{"DeleteUserDictWord", func() error {
+			return client.DeleteUserDictWord("unknown")
+		}, "/user_dict_word/unknown", "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil {
+				t.Fatal("no error for a non-2xx response")
+			}
+			for _, part := range []string{tt.path, tt.status} {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("error %q does not contain %q", err, part)
+				}
+			}
+			if tt.status != "404" && !strings.Contains(err.Error(), "something went wrong") {
+				t.Errorf("error %q does not contain the body", err)
+			}
+		})
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	var client = NewClient(server.URL)
+	server.Close()
+
+	_, err := client.GetVersion()
+	if err == nil || !strings.Contains(err.Error(), "/version") {
+		t.Errorf("GetVersion = %v, want an error of /version", err)
+	}
+}
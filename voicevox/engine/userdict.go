@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// UserDictWord is a word of the user dictionary as returned by GET /user_dict.
+type UserDictWord struct {
+	Surface       string `json:"surface"`
+	Pronunciation string `json:"pronunciation"`
+	AccentType    int    `json:"accent_type"`
+	Priority      int    `json:"priority"`
+	PartOfSpeech  string `json:"part_of_speech"`
+	MoraCount     int    `json:"mora_count"`
+}
+
+// UserDictWordParams are the parameters to add or update a word.
+// WordType is one of PROPER_NOUN, COMMON_NOUN, VERB, ADJECTIVE and SUFFIX.
+// Priority is between 0 and 10. The engine default is used for an empty WordType or a nil Priority.
+type UserDictWordParams struct {
+	Surface       string
+	Pronunciation string
+	AccentType    int
+	WordType      string
+	Priority      *int
+}
+
+func (p UserDictWordParams) query() url.Values {
+	var query = url.Values{}
+	query.Set("surface", p.Surface)
+	query.Set("pronunciation", p.Pronunciation)
+	query.Set("accent_type", strconv.Itoa(p.AccentType))
+	if p.WordType != "" {
+		query.Set("word_type", p.WordType)
+	}
+	if p.Priority != nil {
+		query.Set("priority", strconv.Itoa(*p.Priority))
+	}
+	return query
+}
+
+// GetUserDict returns the words of the user dictionary by their UUIDs.
+func (c *Client) GetUserDict() (map[string]UserDictWord, error) {
+	var words map[string]UserDictWord
+	err := c.getJSON("/user_dict", nil, &words)
+	if err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// AddUserDictWord adds a word and returns its UUID.
+func (c *Client) AddUserDictWord(params UserDictWordParams) (string, error) {
+	b, err := c.do(http.MethodPost, "/user_dict_word", params.query(), nil, "")
+	if err != nil {
+		return "", err
+	}
+
+	var uuid string
+	err = json.Unmarshal(b, &uuid)
+	if err != nil {
+		return "", fmt.Errorf("/user_dict_word: Unmarshal: %v", err)
+	}
+	return uuid, nil
+}
+
+// UpdateUserDictWord replaces the word of uuid.
+func (c *Client) UpdateUserDictWord(uuid string, params UserDictWordParams) error {
+	_, err := c.do(http.MethodPut, "/user_dict_word/"+url.PathEscape(uuid), params.query(), nil, "")
+	return err
+}
+
+// DeleteUserDictWord deletes the word of uuid.
+func (c *Client) DeleteUserDictWord(uuid string) error {
+	_, err := c.do(http.MethodDelete, "/user_dict_word/"+url.PathEscape(uuid), nil, nil, "")
+	return err
+}
@@ -1,5 +1,5 @@
-//go:build linux
-// +build linux
+//go:build linux && !novoicevox
+// +build linux,!novoicevox
 
 package voicevox
 
@@ -240,7 +240,10 @@ func Synthesis(audioQueryJSON string, speakerID uint32, options VoicevoxSynthesi
 	lengthGo := uint(cOutputWavLength)
 
 	rawOutput := (*byte)(unsafe.Pointer(cOutputWav))
-	outputWav := unsafe.Slice(rawOutput, lengthGo)
+
+	// copy the output before freeing it
+	outputWav := make([]byte, lengthGo)
+	copy(outputWav, unsafe.Slice(rawOutput, lengthGo))
 
 	C.voicevox_wav_free(cOutputWav)
 
@@ -0,0 +1,79 @@
+package voicevox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type VoicevoxSpeakerMeta struct {
+	Name        string              `json:"name"`
+	Styles      []VoicevoxStyleMeta `json:"styles"`
+	SpeakerUUID string              `json:"speaker_uuid"`
+	Version     string              `json:"version"`
+}
+
+type VoicevoxStyleMeta struct {
+	Name string `json:"name"`
+	ID   uint32 `json:"id"`
+}
+
+// ParseMetas parses the JSON returned by GetMetasJSON.
+func ParseMetas(metasJSON string) ([]VoicevoxSpeakerMeta, error) {
+	var metas []VoicevoxSpeakerMeta
+	err := json.Unmarshal([]byte(metasJSON), &metas)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal: %v", err)
+	}
+	return metas, nil
+}
+
+// FindSpeakerID looks up the speaker ID (style ID) by its name.
+// name can be a numeric ID, a speaker name such as "ずんだもん",
+// or a speaker name with its style such as "ずんだもん/あまあま" or "ずんだもん(あまあま)".
+// When the style is omitted, "ノーマル" or the first style of the speaker is used.
+func FindSpeakerID(metas []VoicevoxSpeakerMeta, name string) (uint32, error) {
+	name = strings.TrimSpace(name)
+
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		for _, meta := range metas {
+			for _, style := range meta.Styles {
+				if style.ID == uint32(id) {
+					return style.ID, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("speaker ID %d was not found", id)
+	}
+
+	var speakerName, styleName = name, ""
+	if i := strings.IndexAny(name, "/("); i >= 0 {
+		speakerName = name[:i]
+		styleName = strings.TrimSuffix(name[i+1:], ")")
+	}
+
+	for _, meta := range metas {
+		if meta.Name != speakerName || len(meta.Styles) == 0 {
+			continue
+		}
+
+		if styleName == "" {
+			for _, style := range meta.Styles {
+				if style.Name == "ノーマル" {
+					return style.ID, nil
+				}
+			}
+			return meta.Styles[0].ID, nil
+		}
+
+		for _, style := range meta.Styles {
+			if style.Name == styleName {
+				return style.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("style %q of %q was not found", styleName, speakerName)
+	}
+
+	return 0, fmt.Errorf("speaker %q was not found", speakerName)
+}
@@ -52,4 +52,139 @@ type VoicevoxTtsOptions struct {
 	EnableInterrogativeUpspeak bool
 }
 
-type VoicevoxUserDictWord struct{}
+type VoicevoxUserDictWordType int32
+
+const (
+	/**
+	 * 固有名詞
+	 */
+	VOICEVOX_USER_DICT_WORD_TYPE_PROPER_NOUN VoicevoxUserDictWordType = iota
+	/**
+	 * 一般名詞
+	 */
+	VOICEVOX_USER_DICT_WORD_TYPE_COMMON_NOUN
+	/**
+	 * 動詞
+	 */
+	VOICEVOX_USER_DICT_WORD_TYPE_VERB
+	/**
+	 * 形容詞
+	 */
+	VOICEVOX_USER_DICT_WORD_TYPE_ADJECTIVE
+	/**
+	 * 接尾辞
+	 */
+	VOICEVOX_USER_DICT_WORD_TYPE_SUFFIX
+)
+
+type VoicevoxUserDictWord struct {
+	/**
+	 * 表記
+	 */
+	Surface string `json:"surface"`
+	/**
+	 * 読み（カタカナ）
+	 */
+	Pronunciation string `json:"pronunciation"`
+	/**
+	 * アクセント型（音が下がる場所のモーラ位置、0は平板型）
+	 */
+	AccentType int `json:"accent_type"`
+	/**
+	 * 単語の種類
+	 */
+	WordType VoicevoxUserDictWordType `json:"word_type"`
+	/**
+	 * 優先度（0〜10）
+	 */
+	Priority uint32 `json:"priority"`
+}
+
+type VoicevoxAudioQuery struct {
+	/**
+	 * アクセント句の配列
+	 */
+	AccentPhrases []VoicevoxAccentPhrase `json:"accent_phrases"`
+	/**
+	 * 全体の話速
+	 */
+	SpeedScale float32 `json:"speed_scale"`
+	/**
+	 * 全体の音高
+	 */
+	PitchScale float32 `json:"pitch_scale"`
+	/**
+	 * 全体の抑揚
+	 */
+	IntonationScale float32 `json:"intonation_scale"`
+	/**
+	 * 全体の音量
+	 */
+	VolumeScale float32 `json:"volume_scale"`
+	/**
+	 * 音声の前の無音時間
+	 */
+	PrePhonemeLength float32 `json:"pre_phoneme_length"`
+	/**
+	 * 音声の後の無音時間
+	 */
+	PostPhonemeLength float32 `json:"post_phoneme_length"`
+	/**
+	 * 音声データの出力サンプリングレート
+	 */
+	OutputSamplingRate uint32 `json:"output_sampling_rate"`
+	/**
+	 * 音声データをステレオ出力するか否か
+	 */
+	OutputStereo bool `json:"output_stereo"`
+	/**
+	 * aquestalk形式の読み仮名
+	 */
+	Kana string `json:"kana"`
+}
+
+type VoicevoxAccentPhrase struct {
+	/**
+	 * モーラの配列
+	 */
+	Moras []VoicevoxMora `json:"moras"`
+	/**
+	 * アクセント箇所
+	 */
+	Accent uint32 `json:"accent"`
+	/**
+	 * 後ろに無音を付けるかどうか
+	 */
+	PauseMora *VoicevoxMora `json:"pause_mora"`
+	/**
+	 * 疑問系かどうか
+	 */
+	IsInterrogative bool `json:"is_interrogative"`
+}
+
+type VoicevoxMora struct {
+	/**
+	 * 文字
+	 */
+	Text string `json:"text"`
+	/**
+	 * 子音の音素
+	 */
+	Consonant *string `json:"consonant"`
+	/**
+	 * 子音の音長
+	 */
+	ConsonantLength *float32 `json:"consonant_length"`
+	/**
+	 * 母音の音素
+	 */
+	Vowel string `json:"vowel"`
+	/**
+	 * 母音の音長
+	 */
+	VowelLength float32 `json:"vowel_length"`
+	/**
+	 * 音高
+	 */
+	Pitch float32 `json:"pitch"`
+}
@@ -0,0 +1,266 @@
+package voicevox
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var wordTypeNames = map[VoicevoxUserDictWordType]string{
+	VOICEVOX_USER_DICT_WORD_TYPE_PROPER_NOUN: "PROPER_NOUN",
+	VOICEVOX_USER_DICT_WORD_TYPE_COMMON_NOUN: "COMMON_NOUN",
+	VOICEVOX_USER_DICT_WORD_TYPE_VERB:        "VERB",
+	VOICEVOX_USER_DICT_WORD_TYPE_ADJECTIVE:   "ADJECTIVE",
+	VOICEVOX_USER_DICT_WORD_TYPE_SUFFIX:      "SUFFIX",
+}
+
+// String returns the name used by VOICEVOX Engine, such as "PROPER_NOUN".
+func (t VoicevoxUserDictWordType) String() string {
+	return wordTypeNames[t]
+}
+
+func (t VoicevoxUserDictWordType) MarshalText() ([]byte, error) {
+	name, ok := wordTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("invalid word type %d", t)
+	}
+	return []byte(name), nil
+}
+
+func (t *VoicevoxUserDictWordType) UnmarshalText(b []byte) error {
+	for wordType, name := range wordTypeNames {
+		if name == string(b) {
+			*t = wordType
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid word type %q", string(b))
+}
+
+// NewUserDictWord returns a proper noun of the default priority.
+func NewUserDictWord(surface, pronunciation string, accentType int) VoicevoxUserDictWord {
+	return VoicevoxUserDictWord{
+		Surface:       surface,
+		Pronunciation: pronunciation,
+		AccentType:    accentType,
+		WordType:      VOICEVOX_USER_DICT_WORD_TYPE_PROPER_NOUN,
+		Priority:      5,
+	}
+}
+
+// Validate checks the pronunciation, the accent type and the priority of the word.
+func (w VoicevoxUserDictWord) Validate() error {
+	if w.Surface == "" {
+		return errors.New("surface is empty")
+	}
+
+	moras, err := CountMoras(w.Pronunciation)
+	if err != nil {
+		return err
+	}
+
+	if w.AccentType < 0 || w.AccentType > moras {
+		return fmt.Errorf("accent type must be between 0 and %d", moras)
+	}
+
+	if w.Priority > 10 {
+		return errors.New("priority must be between 0 and 10")
+	}
+
+	if _, ok := wordTypeNames[w.WordType]; !ok {
+		return fmt.Errorf("invalid word type %d", w.WordType)
+	}
+
+	return nil
+}
+
+// CountMoras returns the number of the moras of a katakana pronunciation.
+func CountMoras(pronunciation string) (int, error) {
+	if pronunciation == "" {
+		return 0, errors.New("pronunciation is empty")
+	}
+
+	var moras = 0
+	for _, r := range pronunciation {
+		switch {
+		case strings.ContainsRune("ァィゥェォャュョヮ", r):
+			// a small kana is a part of the previous mora
+		case r == 'ー' || (r >= 'ア' && r <= 'ヴ'):
+			moras++
+		default:
+			return 0, fmt.Errorf("pronunciation must be katakana: %q", string(r))
+		}
+	}
+
+	if moras == 0 {
+		return 0, errors.New("pronunciation has no mora")
+	}
+
+	return moras, nil
+}
+
+// UserDict is a user dictionary. Words are keyed by their UUIDs.
+type UserDict struct {
+	mu    sync.Mutex
+	words map[string]VoicevoxUserDictWord
+}
+
+func NewUserDict() *UserDict {
+	return &UserDict{words: map[string]VoicevoxUserDictWord{}}
+}
+
+// Load replaces the words with the ones in the JSON file at path.
+func (d *UserDict) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ReadFile: %v", err)
+	}
+
+	var words map[string]VoicevoxUserDictWord
+	err = json.Unmarshal(b, &words)
+	if err != nil {
+		return fmt.Errorf("Unmarshal: %v", err)
+	}
+
+	for uuid, word := range words {
+		if err := word.Validate(); err != nil {
+			return fmt.Errorf("%s: %v", uuid, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.words = words
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Save writes the words to the JSON file at path.
+func (d *UserDict) Save(path string) error {
+	d.mu.Lock()
+	b, err := json.MarshalIndent(d.words, "", "  ")
+	d.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Marshal: %v", err)
+	}
+
+	err = os.WriteFile(path, b, 0644)
+	if err != nil {
+		return fmt.Errorf("WriteFile: %v", err)
+	}
+
+	return nil
+}
+
+// AddWord adds word and returns its UUID.
+func (d *UserDict) AddWord(word VoicevoxUserDictWord) (string, error) {
+	err := word.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	var uuid = newUUID()
+
+	d.mu.Lock()
+	d.words[uuid] = word
+	d.mu.Unlock()
+
+	return uuid, nil
+}
+
+// UpdateWord replaces the word of uuid.
+func (d *UserDict) UpdateWord(uuid string, word VoicevoxUserDictWord) error {
+	err := word.Validate()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.words[uuid]; !ok {
+		return fmt.Errorf("word %s was not found", uuid)
+	}
+	d.words[uuid] = word
+
+	return nil
+}
+
+// RemoveWord removes the word of uuid.
+func (d *UserDict) RemoveWord(uuid string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.words[uuid]; !ok {
+		return fmt.Errorf("word %s was not found", uuid)
+	}
+	delete(d.words, uuid)
+
+	return nil
+}
+
+// FindWord returns the UUID and the word whose surface is surface.
+func (d *UserDict) FindWord(surface string) (string, VoicevoxUserDictWord, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for uuid, word := range d.words {
+		if word.Surface == surface {
+			return uuid, word, true
+		}
+	}
+	return "", VoicevoxUserDictWord{}, false
+}
+
+// Words returns a copy of the words.
+func (d *UserDict) Words() map[string]VoicevoxUserDictWord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var words = make(map[string]VoicevoxUserDictWord, len(d.words))
+	for uuid, word := range d.words {
+		words[uuid] = word
+	}
+	return words
+}
+
+// ReplaceSurfaces replaces the surfaces in text with their pronunciations.
+// Longer surfaces and then higher priorities are replaced first.
+// This is for VOICEVOX Core 0.14, which cannot give a user dictionary to Open JTalk.
+func (d *UserDict) ReplaceSurfaces(text string) string {
+	var words = d.Words()
+	if len(words) == 0 {
+		return text
+	}
+
+	var list = make([]VoicevoxUserDictWord, 0, len(words))
+	for _, word := range words {
+		list = append(list, word)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Surface) != len(list[j].Surface) {
+			return len(list[i].Surface) > len(list[j].Surface)
+		}
+		return list[i].Priority > list[j].Priority
+	})
+
+	var pairs = make([]string, 0, len(list)*2)
+	for _, word := range list {
+		pairs = append(pairs, word.Surface, word.Pronunciation)
+	}
+
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func newUUID() string {
+	var b = make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
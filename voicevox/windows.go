@@ -1,5 +1,5 @@
-//go:build windows
-// +build windows
+//go:build windows && !novoicevox
+// +build windows,!novoicevox
 
 package voicevox
 
@@ -92,7 +92,7 @@ func Finalize() {
 	finalize_proc.Call()
 }
 
-func GetMetasJson() string {
+func GetMetasJSON() string {
 	r1, _, _ := get_metas_json_proc.Call()
 	return UTF8PtrToString((*byte)(unsafe.Pointer(r1)))
 }
@@ -260,7 +260,12 @@ func Synthesis(
 		return nil, fmt.Errorf(ErrorResultToMessage(ResultCode(r1)))
 	}
 
-	output := unsafe.Slice(output_wav, output_wav_length)
+	// copy the output before freeing it, as the linux implementation does
+	var rawOutput = unsafe.Slice(output_wav, output_wav_length)
+	output := make([]byte, output_wav_length)
+	copy(output, rawOutput)
+	WavFree(rawOutput)
+
 	return output, nil
 }
 